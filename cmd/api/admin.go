@@ -0,0 +1,41 @@
+// cmd/api/admin.go
+// This file contains operator endpoints under /v1/admin. They change how the
+// running server behaves, so they require a privileged caller (a valid
+// X-API-Key, see authenticate).
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+// maintenanceInput is the body of PUT /v1/admin/maintenance.
+type maintenanceInput struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// setMaintenanceHandler handles PUT /v1/admin/maintenance.
+// It reads {"enabled": true|false} and turns maintenance mode on or off
+// without a restart, e.g. around a deploy. maintenanceMode lets this route
+// through even while writes are rejected, so maintenance can be ended the
+// same way it was started.
+func (app *applicationDependencies) setMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.contextIsPrivileged(r) {
+		app.apiKeyRequiredResponse(w, r)
+		return
+	}
+
+	var input maintenanceInput
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.readJSONErrorResponse(w, r, err)
+		return
+	}
+	if input.Enabled == nil {
+		app.badRequestResponse(w, r, errors.New("body must contain an enabled boolean"))
+		return
+	}
+
+	app.setMaintenance(*input.Enabled, app.contextGetActor(r))
+	app.respondOK(w, r, envelope{"maintenance": app.maintenance.Load()})
+}
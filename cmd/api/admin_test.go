@@ -0,0 +1,43 @@
+// cmd/api/admin_test.go
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSetMaintenanceHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		privileged bool
+		body       string
+		wantStatus int
+		wantState  bool
+	}{
+		{"enable", true, `{"enabled": true}`, http.StatusOK, true},
+		{"no API key", false, `{"enabled": true}`, http.StatusUnauthorized, false},
+		{"missing enabled", true, `{}`, http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+
+			r := httptest.NewRequest(http.MethodPut, "/v1/admin/maintenance", strings.NewReader(tt.body))
+			if tt.privileged {
+				r = app.contextSetActor(app.contextSetPrivileged(r), "secret")
+			}
+			rr := httptest.NewRecorder()
+			app.setMaintenanceHandler(rr, r)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status = %d; want %d: %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if got := app.maintenance.Load(); got != tt.wantState {
+				t.Errorf("maintenance = %t; want %t", got, tt.wantState)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// cmd/api/bulk.go
+// This file contains PATCH /v1/books/bulk, which sets the same fields on many
+// books in one request for catalogue cleanups.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aoideee/lab4-tyshadaniels/internal/data"
+	"github.com/aoideee/lab4-tyshadaniels/internal/validator"
+)
+
+// maxBulkIDs caps the ids list of PATCH /v1/books/bulk. It matches
+// maxIDsPerList so one list page can always be updated in one request.
+const maxBulkIDs = maxIDsPerList
+
+// errBulkInvalid rolls back a bulk update that would leave one of its books
+// invalid or change an -immutable-fields field; the fields are reported on v.
+var errBulkInvalid = errors.New("bulk update leaves a book invalid")
+
+// bulkUpdateBooksHandler handles PATCH /v1/books/bulk.
+// It reads {"ids": [...], "set": {...}} and applies the set values to every
+// listed book in one transaction, recording an audit entry per book. IDs with
+// no book are skipped and reported in not_found rather than failing the whole
+// request. Each updated book is validated with the same rules as
+// PATCH /v1/books/:id (see validateBook and checkImmutable); if any one of
+// them fails, the whole request fails with a 422.
+func (app *applicationDependencies) bulkUpdateBooksHandler(w http.ResponseWriter, r *http.Request) {
+	var input data.BulkUpdateBookInput
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.readJSONErrorResponse(w, r, err)
+		return
+	}
+
+	set := input.Set
+	if set.Language != nil {
+		lower := strings.ToLower(*set.Language)
+		set.Language = &lower
+	}
+
+	// --- Validation ---
+	v := validator.New()
+	v.Check(len(input.IDs) > 0, "ids", "must be provided")
+	v.Check(len(input.IDs) <= maxBulkIDs, "ids", fmt.Sprintf("must not contain more than %d values", maxBulkIDs))
+	for _, id := range input.IDs {
+		v.Check(id > 0, "ids", "must contain only positive integers")
+	}
+	v.Check(validator.Unique(input.IDs), "ids", "must not contain duplicate values")
+	v.Check(set != data.BulkBookFields{}, "set", "must contain at least one field")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	// Either every listed book that exists is updated or none is.
+	updated := 0
+	notFound := []int64{}
+	err = app.models.WithTx(func(tx data.Models) error {
+		for _, id := range input.IDs {
+			book, err := tx.Books.Get(id)
+			if err != nil {
+				if errors.Is(err, data.ErrRecordNotFound) {
+					notFound = append(notFound, id)
+					continue
+				}
+				return err
+			}
+			before := *book
+
+			set.Apply(book)
+			app.validateBook(v, book)
+			app.checkImmutable(&before, book, v)
+			if !v.Valid() {
+				return errBulkInvalid
+			}
+			if err := tx.Books.Update(book); err != nil {
+				return err
+			}
+			if err := tx.Audit.Record(data.AuditUpdate, id, app.contextGetActor(r), &before, book); err != nil {
+				return err
+			}
+			updated++
+		}
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errBulkInvalid):
+			app.failedValidationResponse(w, r, v)
+		case errors.Is(err, data.ErrDuplicateISBN):
+			app.duplicateISBNResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.respondOK(w, r, envelope{"updated": updated, "not_found": notFound})
+}
@@ -0,0 +1,26 @@
+// cmd/api/clock.go
+package main
+
+import "time"
+
+// Clock tells the application the current time. Handlers read it through
+// app.clock instead of calling time.Now directly, so a test can construct
+// applicationDependencies with a fixed clock and get the same answers for
+// time-sensitive rules (e.g. "no books from the future") on any day.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the Clock used in production: the system time.
+type realClock struct{}
+
+// Now returns the current system time.
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// currentYear returns the current year according to app.clock. It is the
+// latest publication year a book may have.
+func (app *applicationDependencies) currentYear() int {
+	return app.clock.Now().Year()
+}
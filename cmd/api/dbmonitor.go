@@ -0,0 +1,73 @@
+// cmd/api/dbmonitor.go
+// This file contains the background database monitor. database/sql reconnects
+// on its own after the server restarts, but handlers only see that as a burst
+// of 500s; the monitor turns it into a log line and a degraded healthcheck.
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// maxDBPingBackoff caps how far apart pings get while the database is down.
+const maxDBPingBackoff = time.Minute
+
+// monitorDB pings the database every -db-ping-interval until ctx is
+// cancelled. After -db-max-ping-failures consecutive failures it logs a
+// warning and sets app.dbUnhealthy, which the healthcheck reports as
+// degraded; the first successful ping clears it again. While pings keep
+// failing the interval doubles (up to maxDBPingBackoff) so a struggling
+// database is not hammered.
+//
+// It also stores in app.recentWaits how many times the pool made a query wait
+// for a connection during the last -db-ping-interval. That window is fixed,
+// even while pings back off, so every healthcheck in it sees the same count
+// however many probers there are.
+func (app *applicationDependencies) monitorDB(ctx context.Context) {
+	interval := app.config.db.pingInterval
+	failures := 0
+
+	sample := time.NewTicker(app.config.db.pingInterval)
+	defer sample.Stop()
+	lastWaitCount := app.db.Stats().WaitCount
+
+	ping := time.NewTimer(interval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sample.C:
+			waitCount := app.db.Stats().WaitCount
+			app.recentWaits.Store(waitCount - lastWaitCount)
+			lastWaitCount = waitCount
+			continue
+		case <-ping.C:
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err := app.db.PingContext(pingCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return // Shutting down; the failed ping is not the database's fault.
+		}
+
+		if err == nil {
+			if app.dbUnhealthy.Swap(false) {
+				app.logger.Info("database reachable again", "failed_pings", failures)
+			}
+			failures = 0
+			interval = app.config.db.pingInterval
+			ping.Reset(interval)
+			continue
+		}
+
+		failures++
+		if failures >= app.config.db.maxPingFailures && !app.dbUnhealthy.Swap(true) {
+			app.logger.Warn("database unreachable; reporting degraded", "failed_pings", failures, "error", err.Error())
+		}
+		interval = min(interval*2, max(maxDBPingBackoff, app.config.db.pingInterval))
+		ping.Reset(interval)
+	}
+}
@@ -0,0 +1,30 @@
+// cmd/api/debug.go
+// This file contains endpoints that only exist in the development
+// environment, for investigating problems locally. routes registers them
+// only when -env=development, so elsewhere they are plain 404s.
+package main
+
+import (
+	"net"
+	"net/http"
+)
+
+// rateLimitDebugHandler handles GET /debug/ratelimit.
+// It reports how many client IPs the rate limiter is tracking and, for the
+// caller's own IP, the tokens left in its bucket and when it was last seen.
+// This request has already spent one of those tokens.
+func (app *applicationDependencies) rateLimitDebugHandler(w http.ResponseWriter, r *http.Request) {
+	inspector, ok := app.limiter.(limiterInspector)
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.respondOK(w, r, envelope{"ip": ip, "ratelimit": inspector.Inspect(ip)})
+}
@@ -0,0 +1,87 @@
+// cmd/api/envelope.go
+// This file contains the envelope-free JSON responses for clients that want
+// the bare book or array ({"book": {...}} becomes {...}), and the pagination
+// headers sent with list responses, which stand in for the metadata there.
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aoideee/lab4-tyshadaniels/internal/data"
+)
+
+// wantsEnvelope reports whether the response should be wrapped in its named
+// envelope. ?envelope=true or ?envelope=false overrides the -envelope default
+// for a single request; any other value is ignored. XML responses always keep
+// their envelope because an XML document needs a single root element.
+func (app *applicationDependencies) wantsEnvelope(r *http.Request) bool {
+	if prefersXML(r) {
+		return true
+	}
+	if value, err := strconv.ParseBool(r.URL.Query().Get("envelope")); err == nil {
+		return value
+	}
+	return app.config.envelope
+}
+
+// envelopeKeyRX matches the -envelope-key values that are valid as both a
+// JSON key and an XML element name, e.g. "data" or "result_set".
+var envelopeKeyRX = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// envelopeKey returns the envelope key for a book resource: name ("book" or
+// "books") by default, or -envelope-key for both when it is set, for clients
+// that read every body from the same key. Other keys (metadata, warnings)
+// are unchanged.
+func (app *applicationDependencies) envelopeKey(name string) string {
+	if app.config.envelopeKey != "" {
+		return app.config.envelopeKey
+	}
+	return name
+}
+
+// respondBare writes value as JSON with a 200 OK status and no envelope,
+// falling back to a 500 if the response cannot be written.
+func (app *applicationDependencies) respondBare(w http.ResponseWriter, r *http.Request, value any) {
+	w.Header().Add("Vary", "Accept")
+	err := app.writeJSON(w, http.StatusOK, value, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// setPaginationHeaders describes a page of results in headers, for clients
+// and generic REST tooling that read pagination from there rather than from
+// the metadata envelope key: X-Total-Count holds total_records and Link
+// (RFC 8288) holds the first, prev, next, and last page URLs, which keep the
+// request's other query parameters. The URLs are relative (path and query
+// only), so they stay correct behind a TLS-terminating proxy, where r.TLS
+// says nothing about the scheme the client used. An empty result has no
+// pages, so it gets no Link header.
+func (app *applicationDependencies) setPaginationHeaders(w http.ResponseWriter, r *http.Request, metadata data.Metadata) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(metadata.TotalRecords))
+	if metadata.TotalRecords == 0 {
+		return
+	}
+
+	pageURL := func(page int) string {
+		qs := r.URL.Query()
+		qs.Set("page", strconv.Itoa(page))
+		qs.Set("page_size", strconv.Itoa(metadata.PageSize))
+		u := url.URL{Path: r.URL.Path, RawQuery: qs.Encode()}
+		return u.String()
+	}
+
+	links := []string{`<` + pageURL(metadata.FirstPage) + `>; rel="first"`}
+	if metadata.CurrentPage > metadata.FirstPage {
+		links = append(links, `<`+pageURL(metadata.CurrentPage-1)+`>; rel="prev"`)
+	}
+	if metadata.CurrentPage < metadata.LastPage {
+		links = append(links, `<`+pageURL(metadata.CurrentPage+1)+`>; rel="next"`)
+	}
+	links = append(links, `<`+pageURL(metadata.LastPage)+`>; rel="last"`)
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
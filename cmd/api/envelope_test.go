@@ -0,0 +1,36 @@
+// cmd/api/envelope_test.go
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aoideee/lab4-tyshadaniels/internal/data"
+)
+
+func TestSetPaginationHeadersRelativeLinks(t *testing.T) {
+	app := newTestApplication(t)
+	metadata := data.Metadata{CurrentPage: 2, PageSize: 10, FirstPage: 1, LastPage: 3, TotalRecords: 25}
+	want := `</api/v1/books?language=en&page=1&page_size=10>; rel="first", ` +
+		`</api/v1/books?language=en&page=1&page_size=10>; rel="prev", ` +
+		`</api/v1/books?language=en&page=3&page_size=10>; rel="next", ` +
+		`</api/v1/books?language=en&page=3&page_size=10>; rel="last"`
+
+	// Behind a TLS-terminating proxy r.TLS is nil even though the client used
+	// HTTPS, so the links must not depend on it either way.
+	for _, state := range []*tls.ConnectionState{nil, {}} {
+		r := httptest.NewRequest(http.MethodGet, "http://books.example.com/api/v1/books?language=en&page=2", nil)
+		r.TLS = state
+		rr := httptest.NewRecorder()
+		app.setPaginationHeaders(rr, r, metadata)
+
+		if got := rr.Header().Get("Link"); got != want {
+			t.Errorf("TLS %t: Link =\n%s\nwant\n%s", state != nil, got, want)
+		}
+		if got := rr.Header().Get("X-Total-Count"); got != "25" {
+			t.Errorf("X-Total-Count = %q; want %q", got, "25")
+		}
+	}
+}
@@ -4,23 +4,36 @@
 package main
 
 import (
+	"errors"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aoideee/lab4-tyshadaniels/internal/data"
+	"github.com/aoideee/lab4-tyshadaniels/internal/validator"
 )
 
 // logError logs an internal error at ERROR level with the request method and URL for context.
-func (app *applicationDependencies) logError(r *http.Request, err error) {
-	app.logger.Error(err.Error(),
+// Any extra attrs (e.g. a stack trace) are appended after the request details.
+func (app *applicationDependencies) logError(r *http.Request, err error, attrs ...slog.Attr) {
+	args := []any{
 		slog.String("request_method", r.Method),
 		slog.String("request_url", r.URL.String()),
-	)
+	}
+	for _, attr := range attrs {
+		args = append(args, attr)
+	}
+	app.logger.Error(err.Error(), args...)
 }
 
 // errorResponse sends a JSON error envelope with the given status code and message.
 // It is the low-level building block used by all the specific error helpers below.
 func (app *applicationDependencies) errorResponse(w http.ResponseWriter, r *http.Request, status int, message any) {
 	data := envelope{"error": message}
-	err := app.writeJSON(w, status, data, nil)
+	err := app.writeResponse(w, r, status, data, nil)
 	if err != nil {
 		app.logError(r, err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -28,21 +41,53 @@ func (app *applicationDependencies) errorResponse(w http.ResponseWriter, r *http
 }
 
 // serverErrorResponse logs a 500-level error and sends a generic message to the client.
-// We never expose internal error details to the client for security reasons.
+// Internal error details are only appended in development; staging and production
+// never expose them to the client for security reasons. A database that is
+// only temporarily refusing connections gets a 503 instead (see
+// databaseUnavailableResponse), so every handler reports it the same way.
 func (app *applicationDependencies) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logError(r, err)
-	app.errorResponse(w, r, http.StatusInternalServerError, "the server encountered a problem and could not process your request")
+	if errors.Is(err, data.ErrDatabaseUnavailable) {
+		app.databaseUnavailableResponse(w, r)
+		return
+	}
+	app.errorResponse(w, r, http.StatusInternalServerError, app.serverErrorMessage(err))
 }
 
-// notFoundResponse sends a 404 Not Found error.
+// serverErrorMessage returns the client-facing text for a 500 caused by err.
+func (app *applicationDependencies) serverErrorMessage(err error) string {
+	message := "the server encountered a problem and could not process your request"
+	if app.config.environment == "development" {
+		message += ": " + err.Error()
+	}
+	return message
+}
+
+// notFoundResponse sends a 404 Not Found error with the -not-found-message
+// text (and -not-found-code, see codedError). It is also the router's
+// NotFound handler, so unknown paths get the same body as missing records.
 func (app *applicationDependencies) notFoundResponse(w http.ResponseWriter, r *http.Request) {
-	app.errorResponse(w, r, http.StatusNotFound, "the requested resource could not be found")
+	errs := app.config.routingErrors
+	app.errorResponse(w, r, http.StatusNotFound, codedError(errs.notFoundCode, errs.notFoundMessage))
 }
 
-// methodNotAllowedResponse sends a 405 Method Not Allowed error.
+// methodNotAllowedResponse sends a 405 Method Not Allowed error with the
+// -method-not-allowed-message text, "{method}" replaced by the request's
+// method (and -method-not-allowed-code, see codedError).
 func (app *applicationDependencies) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
-	message := "the " + r.Method + " method is not supported for this resource"
-	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
+	errs := app.config.routingErrors
+	message := strings.ReplaceAll(errs.methodNotAllowedMessage, "{method}", r.Method)
+	app.errorResponse(w, r, http.StatusMethodNotAllowed, codedError(errs.methodNotAllowedCode, message))
+}
+
+// codedError returns the value of the "error" key for a routing error: just
+// message by default, or {"code": code, "message": message} when a code is
+// configured, for gateways that expect a machine-readable error code.
+func codedError(code, message string) any {
+	if code == "" {
+		return message
+	}
+	return map[string]string{"code": code, "message": message}
 }
 
 // badRequestResponse sends a 400 Bad Request error with the error message from the caller.
@@ -50,13 +95,121 @@ func (app *applicationDependencies) badRequestResponse(w http.ResponseWriter, r
 	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
 }
 
+// readJSONErrorResponse sends the response for an error returned by readJSON:
+// a 422 for a problem with one particular field (a *data.FieldError, such as
+// an explicit null on a required field) and a 400 for anything else.
+func (app *applicationDependencies) readJSONErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var fieldErr *data.FieldError
+	if errors.As(err, &fieldErr) {
+		v := validator.New()
+		v.AddError(fieldErr.Field, fieldErr.Message)
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+	app.badRequestResponse(w, r, err)
+}
+
 // failedValidationResponse sends a 422 Unprocessable Entity response containing
-// the field-level validation errors collected by a Validator.
-func (app *applicationDependencies) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
+// the field-level validation errors collected by v. By default each field has
+// one message ({field: message}); with -validation-all-errors every message is
+// listed ({field: [message, ...]}). Either way fields are written in
+// alphabetical order: encoding/json sorts map keys, and encodeXMLValue does the
+// same, so the body is byte-for-byte stable for a given set of failures.
+//
+// At most -max-validation-errors fields are listed, so a crafted bulk body
+// cannot bloat the response; when more failed, the alphabetically first ones
+// are kept and "truncated": true is added next to "error".
+func (app *applicationDependencies) failedValidationResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator) {
+	truncated := v.Truncate(app.config.maxValidationErrors)
+
+	var message any = v.Errors
+	if app.config.validationAllErrors {
+		message = v.AllErrors
+	}
+	if !truncated {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, message)
+		return
+	}
+
+	err := app.writeResponse(w, r, http.StatusUnprocessableEntity, envelope{"error": message, "truncated": true}, nil)
+	if err != nil {
+		app.logError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// rateLimitExceededResponse sends a 429 Too Many Requests error (or a 503 when
+// -limiter-status says so) with the -limiter-message text and a Retry-After
+// header rounded up to whole seconds.
+func (app *applicationDependencies) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	app.errorResponse(w, r, app.config.limiter.status, app.config.limiter.message)
+}
+
+// preconditionFailedResponse sends a 412 Precondition Failed error with the
+// caller's message.
+func (app *applicationDependencies) preconditionFailedResponse(w http.ResponseWriter, r *http.Request, message string) {
+	app.errorResponse(w, r, http.StatusPreconditionFailed, message)
+}
+
+// isbnTakenResponse sends the 412 Precondition Failed for a conditional
+// create (If-None-Match: *) whose ISBN belongs to existing, with a Location
+// header pointing at that book.
+func (app *applicationDependencies) isbnTakenResponse(w http.ResponseWriter, r *http.Request, existing *data.Book) {
+	w.Header().Set("Location", app.config.basePath+"/v1/books/"+strconv.FormatInt(existing.ID, 10))
+	app.preconditionFailedResponse(w, r, "a book with this ISBN already exists")
+}
+
+// duplicateISBNResponse sends a 409 Conflict error for a create or update
+// whose ISBN already belongs to another book.
+func (app *applicationDependencies) duplicateISBNResponse(w http.ResponseWriter, r *http.Request) {
+	app.errorResponse(w, r, http.StatusConflict, "a book with this ISBN already exists")
+}
+
+// pageOutOfRangeResponse sends a 404 Not Found error for a list page past the
+// last one, with -strict-pagination set.
+func (app *applicationDependencies) pageOutOfRangeResponse(w http.ResponseWriter, r *http.Request, lastPage int) {
+	app.errorResponse(w, r, http.StatusNotFound, "requested page exceeds last page ("+strconv.Itoa(lastPage)+")")
+}
+
+// invalidAPIKeyResponse sends a 401 Unauthorized error for an X-API-Key
+// header that does not match any configured key.
+func (app *applicationDependencies) invalidAPIKeyResponse(w http.ResponseWriter, r *http.Request) {
+	app.errorResponse(w, r, http.StatusUnauthorized, "invalid API key")
+}
+
+// apiKeyRequiredResponse sends a 401 Unauthorized error for an endpoint that
+// only privileged callers (with a valid X-API-Key) may use.
+func (app *applicationDependencies) apiKeyRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	app.errorResponse(w, r, http.StatusUnauthorized, "this endpoint requires a valid X-API-Key")
+}
+
+// databaseUnavailableResponse sends a 503 Service Unavailable error with a
+// Retry-After header when PostgreSQL is refusing new connections, e.g. because
+// max_connections has been reached. Unlike a 500 it tells clients to retry.
+func (app *applicationDependencies) databaseUnavailableResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", strconv.Itoa(databaseRetryAfterSeconds))
+	app.errorResponse(w, r, http.StatusServiceUnavailable, "the database is temporarily unavailable; please retry shortly")
+}
+
+// serverBusyResponse sends a 503 Service Unavailable error with a Retry-After
+// header when -max-concurrent-requests requests are already in flight.
+func (app *applicationDependencies) serverBusyResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", strconv.Itoa(busyRetryAfterSeconds))
+	app.errorResponse(w, r, http.StatusServiceUnavailable, "the server is busy; please retry shortly")
+}
+
+// readOnlyModeResponse sends a 405 Method Not Allowed error for a write to a
+// server started with -read-only. The Allow header lists the methods that do
+// work in this mode.
+func (app *applicationDependencies) readOnlyModeResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+	app.errorResponse(w, r, http.StatusMethodNotAllowed, "API is in read-only mode")
 }
 
-// rateLimitExceededResponse sends a 429 Too Many Requests error.
-func (app *applicationDependencies) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
-	app.errorResponse(w, r, http.StatusTooManyRequests, "rate limit exceeded")
+// maintenanceModeResponse sends a 503 Service Unavailable error with a
+// Retry-After header telling clients when to try their write again.
+func (app *applicationDependencies) maintenanceModeResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", strconv.Itoa(maintenanceRetryAfterSeconds))
+	app.errorResponse(w, r, http.StatusServiceUnavailable, "the API is in maintenance mode; write requests are temporarily disabled")
 }
@@ -0,0 +1,116 @@
+// cmd/api/errors_test.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aoideee/lab4-tyshadaniels/internal/data"
+	"github.com/aoideee/lab4-tyshadaniels/internal/validator"
+)
+
+func TestFailedValidationResponseIsDeterministic(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"json", "", "{\n\t\"error\": {\n\t\t\"isbn\": \"must be provided\",\n\t\t\"publisher\": \"must be provided\",\n\t\t\"title\": \"must be provided\"\n\t}\n}\n"},
+		{"xml", "application/xml", `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+			"<response>\n\t<error>\n\t\t<isbn>must be provided</isbn>\n\t\t<publisher>must be provided</publisher>\n\t\t<title>must be provided</title>\n\t</error>\n</response>\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Repeat so that random map iteration would show up as a mismatch.
+			for range 20 {
+				v := validator.New()
+				for _, field := range []string{"title", "publisher", "isbn"} {
+					v.AddError(field, "must be provided")
+				}
+
+				r := httptest.NewRequest(http.MethodPost, "/v1/books", nil)
+				if tt.accept != "" {
+					r.Header.Set("Accept", tt.accept)
+				}
+				rr := httptest.NewRecorder()
+				app.failedValidationResponse(rr, r, v)
+
+				if rr.Code != http.StatusUnprocessableEntity {
+					t.Fatalf("status = %d; want %d", rr.Code, http.StatusUnprocessableEntity)
+				}
+				if got := rr.Body.String(); got != tt.want {
+					t.Fatalf("body =\n%s\nwant\n%s", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestServerErrorResponseDatabaseUnavailable(t *testing.T) {
+	app := newTestApplication(t)
+	r := httptest.NewRequest(http.MethodGet, "/v1/books", nil)
+	rr := httptest.NewRecorder()
+
+	err := fmt.Errorf("%w: sorry, too many clients already", data.ErrDatabaseUnavailable)
+	app.serverErrorResponse(rr, r, err)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d; want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+	if got := rr.Header().Get("Retry-After"); got == "" {
+		t.Error("Retry-After header missing")
+	}
+}
+
+func TestISBNTakenResponse(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.basePath = "/api"
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/books", nil)
+	rr := httptest.NewRecorder()
+
+	app.isbnTakenResponse(rr, r, &data.Book{ID: 42})
+
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("status = %d; want %d", rr.Code, http.StatusPreconditionFailed)
+	}
+	if got := rr.Header().Get("Location"); got != "/api/v1/books/42" {
+		t.Errorf("Location = %q; want %q", got, "/api/v1/books/42")
+	}
+}
+
+func TestServerErrorResponseDetailOnlyInDevelopment(t *testing.T) {
+	errSentinel := errors.New("pq: relation \"secret_table\" does not exist")
+
+	tests := []struct {
+		environment string
+		wantDetail  bool
+	}{
+		{"development", true},
+		{"staging", false},
+		{"production", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.environment, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.environment = tt.environment
+			rr := httptest.NewRecorder()
+
+			app.serverErrorResponse(rr, httptest.NewRequest(http.MethodGet, "/v1/books", nil), errSentinel)
+
+			if rr.Code != http.StatusInternalServerError {
+				t.Errorf("status = %d; want %d", rr.Code, http.StatusInternalServerError)
+			}
+			if got := strings.Contains(rr.Body.String(), "secret_table"); got != tt.wantDetail {
+				t.Errorf("body contains the error detail = %t; want %t: %s", got, tt.wantDetail, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// cmd/api/export.go
+// This file contains the NDJSON export of the catalogue served at
+// GET /v1/books/export.ndjson, for backups and bulk loads into other tools.
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/aoideee/lab4-tyshadaniels/internal/data"
+	"github.com/aoideee/lab4-tyshadaniels/internal/validator"
+)
+
+// exportBooksHandler handles GET /v1/books/export.ndjson.
+// It accepts the same filter (q, shelf, language, created_*, ids), sort, and
+// fields query parameters as GET /v1/books, but is not paginated: every matching
+// book is written as one JSON object per line (newline-delimited JSON) while
+// the rows are read, so the table is never held in memory.
+//
+// As with streamBooks, headers are sent with the first book, so a query that
+// fails up front still gets a normal 500; a failure mid-export can only be
+// logged and leaves the client with a truncated body.
+func (app *applicationDependencies) exportBooksHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	criteria := app.readBookCriteria(qs, v)
+	filters := data.Filters{
+		Sort:         app.readBookSort(qs, v),
+		SortSafeList: bookSortSafeList,
+		DefaultSort:  app.config.defaultSort,
+		Columns:      app.readBookFields(qs, v), // nil = every column
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	// A full export can outlast -write-timeout, so lift the deadline for this
+	// response only.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		app.logError(r, err)
+	}
+
+	enc := json.NewEncoder(w)
+	started := false
+	err := app.models.Books.Export(criteria, filters, func(book *data.Book) error {
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		// Encode terminates each value with a newline, which is exactly the
+		// NDJSON record separator.
+		if len(filters.Columns) > 0 {
+			return enc.Encode(book.Project(filters.Columns))
+		}
+		return enc.Encode(book)
+	})
+	if err != nil {
+		if !started {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.logError(r, err)
+		return
+	}
+
+	// No matching books is an empty export, not an error.
+	if !started {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,121 @@
+// cmd/api/feed.go
+// This file builds the Atom feed of new arrivals served at
+// GET /v1/books/feed.atom, so patrons can follow the catalogue in a feed reader.
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aoideee/lab4-tyshadaniels/internal/data"
+)
+
+// atomFeed is the <feed> root element of an Atom 1.0 document (RFC 4287).
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// atomAuthor is the feed-level <author>; Atom requires one on the feed or on
+// every entry, and books carry no author of their own.
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// atomLink is an Atom <link> element.
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+// atomEntry is one book in the feed.
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary,omitempty"`
+}
+
+// bookFeedHandler handles GET /v1/books/feed.atom.
+// It returns the -feed-size newest books (by created_at) as an Atom feed.
+// Entry IDs are the books' absolute URLs, which stay stable across updates.
+func (app *applicationDependencies) bookFeedHandler(w http.ResponseWriter, r *http.Request) {
+	books, err := app.models.Books.Recent(app.config.feedSize)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	booksURL := app.absoluteURL(r, "/v1/books")
+	feed := atomFeed{
+		Title:   "New arrivals",
+		ID:      booksURL + "/feed.atom",
+		Updated: app.clock.Now().UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: "Library catalogue"},
+		Link:    atomLink{Rel: "self", Href: booksURL + "/feed.atom"},
+	}
+	// The feed changes when a listed book does, so use the newest change as
+	// its timestamp; an empty catalogue falls back to the current time.
+	if latest := latestUpdate(books); !latest.IsZero() {
+		feed.Updated = latest.Format(time.RFC3339)
+	}
+	for _, book := range books {
+		bookURL := booksURL + "/" + strconv.FormatInt(book.ID, 10)
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   book.Title,
+			ID:      bookURL,
+			Updated: book.UpdatedAt.Format(time.RFC3339),
+			Link:    atomLink{Rel: "alternate", Href: bookURL},
+			Summary: book.Description,
+		})
+	}
+
+	body, err := xml.MarshalIndent(feed, "", "\t")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+	w.Write([]byte("\n"))
+}
+
+// latestUpdate returns the most recent UpdatedAt among books, or the zero
+// time if books is empty.
+func latestUpdate(books []*data.Book) time.Time {
+	var latest time.Time
+	for _, book := range books {
+		if book.UpdatedAt.After(latest) {
+			latest = book.UpdatedAt
+		}
+	}
+	return latest
+}
+
+// absoluteURL returns the absolute URL of path (e.g. "/v1/books") on this
+// server, including -base-path. The scheme and host are -public-url when it
+// is set, which they must be behind a TLS-terminating proxy: otherwise they
+// come from the request (r.TLS and the Host header), and a proxied HTTPS
+// request looks like plain HTTP.
+func (app *applicationDependencies) absoluteURL(r *http.Request, path string) string {
+	if app.config.publicURL != "" {
+		return app.config.publicURL + app.config.basePath + path
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + app.config.basePath + path
+}
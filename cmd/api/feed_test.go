@@ -0,0 +1,40 @@
+// cmd/api/feed_test.go
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAbsoluteURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		publicURL string
+		tls       bool
+		want      string
+	}{
+		{"from plain request", "", false, "http://internal:4000/api/v1/books"},
+		{"from TLS request", "", true, "https://internal:4000/api/v1/books"},
+		// Behind a TLS-terminating proxy the request is plain HTTP.
+		{"public URL", "https://books.example.com", false, "https://books.example.com/api/v1/books"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.config.basePath = "/api"
+			app.config.publicURL = tt.publicURL
+
+			r := httptest.NewRequest(http.MethodGet, "http://internal:4000/api/v1/books/feed.atom", nil)
+			r.TLS = nil
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			if got := app.absoluteURL(r, "/v1/books"); got != tt.want {
+				t.Errorf("absoluteURL = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,42 +6,60 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/aoideee/lab4-tyshadaniels/internal/data"
 	"github.com/aoideee/lab4-tyshadaniels/internal/validator"
+	"github.com/julienschmidt/httprouter"
 )
 
+// bookSortSafeList holds the values GET /v1/books accepts in sort (and that
+// -default-sort may be set to); a "-" prefix means descending.
+var bookSortSafeList = []string{
+	"book_id", "title", "publication_year",
+	"-book_id", "-title", "-publication_year",
+}
+
+// publicMaxPageSize is the largest page_size an ordinary caller may request.
+// Privileged callers (see authenticate) may go up to -privileged-max-page-size.
+const publicMaxPageSize = 100
+
+// checkPageSize records on v a page_size above the caller's cap. There are
+// two tiers: public callers are capped at publicMaxPageSize, while internal
+// tools presenting an API key get the higher -privileged-max-page-size.
+func (app *applicationDependencies) checkPageSize(r *http.Request, pageSize int, v *validator.Validator) {
+	maxPageSize := publicMaxPageSize
+	if app.contextIsPrivileged(r) {
+		maxPageSize = app.config.privilegedMaxPageSize
+	}
+	v.Check(pageSize <= maxPageSize, "page_size", fmt.Sprintf("must be a maximum of %d", maxPageSize))
+}
+
+// maxIDsPerList caps ?ids on GET /v1/books; it equals publicMaxPageSize so the
+// whole set always fits on the default page.
+const maxIDsPerList = publicMaxPageSize
+
 // createBookHandler handles POST /v1/books.
 // It reads a JSON body, validates all fields with a Validator, inserts the record,
-// and responds with 201 Created plus the fully-populated book.
+// and responds with 201 Created plus the fully-populated book. A non-blocking
+// "warnings" array is added when another book already has the same title.
+// With If-None-Match: * the create only happens if no book has the ISBN yet;
+// otherwise the response is 412 Precondition Failed.
 func (app *applicationDependencies) createBookHandler(w http.ResponseWriter, r *http.Request) {
 	var input data.CreateBookInput
 
-	// Decode the request body safely (1MB cap, no unknown fields).
+	// Decode the request body safely (1MB cap, no unknown fields, no null required fields).
 	err := app.readJSON(w, r, &input)
 	if err != nil {
-		app.badRequestResponse(w, r, err)
+		app.readJSONErrorResponse(w, r, err)
 		return
 	}
 
-	// --- Validation ---
-	v := validator.New()
-	v.Check(input.Title != "", "title", "must be provided")
-	v.Check(len(input.Title) <= 255, "title", "must not be more than 255 characters long")
-	v.Check(input.ISBN != "", "isbn", "must be provided")
-	v.Check(len(input.ISBN) == 13, "isbn", "must be exactly 13 characters long")
-	v.Check(input.Publisher != "", "publisher", "must be provided")
-	v.Check(input.PublicationYear > 0, "publication_year", "must be provided")
-	v.Check(input.PublicationYear <= 2026, "publication_year", "must not be in the future")
-	v.Check(input.MinimumAge >= 0, "minimum_age", "must be zero or greater")
-
-	if !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
-		return
-	}
-
-	// Map the validated input onto a new Book struct.
+	// Map the input onto a new Book struct.
 	book := &data.Book{
 		Title:           input.Title,
 		ISBN:            input.ISBN,
@@ -49,24 +67,97 @@ func (app *applicationDependencies) createBookHandler(w http.ResponseWriter, r *
 		PublicationYear: input.PublicationYear,
 		MinimumAge:      input.MinimumAge,
 		Description:     input.Description,
+		ShelfLocation:   input.ShelfLocation,
+		Language:        strings.ToLower(input.Language),
 	}
+	book.Copies = data.DefaultCopies
+	if input.Copies != nil {
+		book.Copies = *input.Copies
+	}
+
+	// --- Validation ---
+	v := validator.New()
+	suppressWarnings := app.readBool(r.URL.Query(), "suppress_warnings", false, v)
+	app.validateBook(v, book)
+	app.checkBookSchema(input, v)
 
-	// Persist the book; Insert() writes the auto-generated ID and timestamps back.
-	err = app.models.Books.Insert(book)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	// Warn (without blocking the insert) when the title is already in the
+	// catalogue, unless the client opts out with ?suppress_warnings=true.
+	var warnings []string
+	if !suppressWarnings {
+		exists, err := app.models.Books.TitleExists(book.Title)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if exists {
+			warnings = append(warnings, "a book with this title already exists")
+		}
+	}
+
+	// If-None-Match: * makes the create conditional on no book having this
+	// ISBN yet, so a retried POST cannot create a duplicate. A match is a 412
+	// whose Location points at the existing book.
+	conditional := strings.TrimSpace(r.Header.Get("If-None-Match")) == "*"
+	if conditional {
+		existing, err := app.models.Books.GetByISBN(book.ISBN)
+		switch {
+		case err == nil:
+			app.isbnTakenResponse(w, r, existing)
+			return
+		case !errors.Is(err, data.ErrRecordNotFound):
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	// Persist the book and its audit entry in one transaction; Insert() writes
+	// the auto-generated ID and timestamps back.
+	err = app.models.WithTx(func(tx data.Models) error {
+		if err := tx.Books.Insert(book); err != nil {
+			return err
+		}
+		return tx.Audit.Record(data.AuditCreate, book.ID, app.contextGetActor(r), nil, book)
+	})
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case conditional && errors.Is(err, data.ErrDuplicateISBN):
+			// Another request created the book after the check above; look it
+			// up so this 412 has the same Location as the one above.
+			existing, err := app.models.Books.GetByISBN(book.ISBN)
+			switch {
+			case err == nil:
+				app.isbnTakenResponse(w, r, existing)
+			case errors.Is(err, data.ErrRecordNotFound):
+				// ...and it has been deleted again since.
+				app.preconditionFailedResponse(w, r, "a book with this ISBN already exists")
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+		case errors.Is(err, data.ErrDuplicateISBN):
+			app.duplicateISBNResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
 	// Respond with the created book and 201 Created.
-	err = app.writeJSON(w, http.StatusCreated, envelope{"book": book}, nil)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
+	resp := envelope{app.envelopeKey("book"): book}
+	if len(warnings) > 0 {
+		resp["warnings"] = warnings
 	}
+	app.respondCreated(w, r, resp, nil)
 }
 
-// showBookHandler handles GET /v1/books/:id.
+// showBookHandler handles GET /v1/books/:id (and HEAD via headOnly).
 // It calls Get(id) directly on the model — no full table scan needed.
+// ?time_format=unix returns the timestamps as Unix seconds (see withTimeFormat).
 func (app *applicationDependencies) showBookHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract and validate the :id URL parameter.
 	id, err := app.readIDParam(r)
@@ -75,6 +166,13 @@ func (app *applicationDependencies) showBookHandler(w http.ResponseWriter, r *ht
 		return
 	}
 
+	v := validator.New()
+	timeFormat := app.readTimeFormat(r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
 	// Fetch the single record from the database by primary key.
 	book, err := app.models.Books.Get(id)
 	if err != nil {
@@ -87,65 +185,516 @@ func (app *applicationDependencies) showBookHandler(w http.ResponseWriter, r *ht
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"book": book}, nil)
+	// The ETag changes whenever the row does, so clients can revalidate cheaply.
+	w.Header().Set("ETag", bookETag(book))
+	body := withTimeFormat(book, timeFormat)
+	if !app.wantsEnvelope(r) {
+		app.respondBare(w, r, body)
+		return
+	}
+	app.respondOK(w, r, envelope{app.envelopeKey("book"): body})
+}
+
+// showBookByISBNHandler handles GET /v1/books/isbn/:isbn.
+// It is the lookup used by barcode scanners and other systems that only know
+// the ISBN. The ISBN is checked before querying so malformed values get a 422
+// rather than a misleading 404.
+func (app *applicationDependencies) showBookByISBNHandler(w http.ResponseWriter, r *http.Request) {
+	isbn := httprouter.ParamsFromContext(r.Context()).ByName("sub")
+
+	v := validator.New()
+	v.Check(len(isbn) == data.ISBNLength, "isbn", fmt.Sprintf("must be exactly %d characters long", data.ISBNLength))
+	timeFormat := app.readTimeFormat(r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	book, err := app.models.Books.GetByISBN(isbn)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("ETag", bookETag(book))
+	body := withTimeFormat(book, timeFormat)
+	if !app.wantsEnvelope(r) {
+		app.respondBare(w, r, body)
+		return
+	}
+	app.respondOK(w, r, envelope{app.envelopeKey("book"): body})
+}
+
+// bookSchemaHandler handles GET /v1/books/schema.
+// It publishes the constraints the create, replace, and update handlers
+// enforce, read from the same data constants (and the clock, for the latest
+// publication year), so clients can build their
+// forms from the server instead of hardcoding limits.
+func (app *applicationDependencies) bookSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	rules := map[string]map[string]any{
+		"title":            {"required": true, "max_length": app.config.maxTitleLength},
+		"isbn":             {"required": true, "length": data.ISBNLength},
+		"publisher":        {"required": true},
+		"publication_year": {"required": true, "min": data.MinPublicationYear, "max": app.currentYear()},
+		"minimum_age":      {"required": true, "min": data.MinMinimumAge},
+		"description":      {"required": false},
+		"shelf_location":   {"required": false, "pattern": validator.ShelfLocationRX.String()},
+		"language":         {"required": false, "length": 2},
+		"copies":           {"required": false, "min": data.MinCopies, "default": data.DefaultCopies},
+	}
+
+	app.respondOK(w, r, envelope{"fields": rules})
+}
+
+// bookHistoryHandler handles GET /v1/books/:id/history.
+// It returns the audit entries for a book, oldest first. History outlives the
+// book, so a deleted book still has one; 404 means the ID was never used.
+func (app *applicationDependencies) bookHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	entries, err := app.models.Audit.ForBook(id)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if len(entries) == 0 {
+		_, err := app.models.Books.Get(id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
 	}
+
+	app.respondOK(w, r, envelope{"history": entries})
 }
 
-// listBooksHandler handles GET /v1/books.
-// It reads optional page, page_size, and sort query parameters, validates them,
-// and returns a paginated list of books together with pagination metadata.
-func (app *applicationDependencies) listBooksHandler(w http.ResponseWriter, r *http.Request) {
-	// The struct we will fill from the URL query string.
-	var queryInput struct {
-		Page     int
-		PageSize int
-		Sort     string
+// bookDiffHandler handles GET /v1/books/:id/diff?from=<audit_id>&to=<audit_id>.
+// A version is the book as it was after one entry of its history (see
+// bookHistoryHandler), identified by the entry's audit_id. The response lists
+// each field that differs between the two versions as {"from": ..., "to": ...}.
+// Returns 404 when either entry does not exist for this book.
+func (app *applicationDependencies) bookDiffHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
 	}
 
-	// Read query parameters with sensible defaults.
 	qs := r.URL.Query()
-	queryInput.Page = app.readInt(qs, "page", 1)
-	queryInput.PageSize = app.readInt(qs, "page_size", 10)
-	queryInput.Sort = app.readString(qs, "sort", "book_id")
+	from := int64(app.readInt(qs, "from", 0))
+	to := int64(app.readInt(qs, "to", 0))
 
-	// --- Validation ---
 	v := validator.New()
-	v.Check(queryInput.Page > 0, "page", "must be greater than zero")
-	v.Check(queryInput.Page <= 10_000_000, "page", "must be a maximum of 10 million")
-	v.Check(queryInput.PageSize > 0, "page_size", "must be greater than zero")
-	v.Check(queryInput.PageSize <= 100, "page_size", "must be a maximum of 100")
-	v.Check(validator.In(queryInput.Sort, "book_id", "title", "publication_year", "-book_id", "-title", "-publication_year"),
-		"sort", "invalid sort value")
+	v.Check(from > 0, "from", "must be a positive audit_id")
+	v.Check(to > 0, "to", "must be a positive audit_id")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	var versions [2]*data.AuditEntry
+	for i, auditID := range []int64{from, to} {
+		entry, err := app.models.Audit.Get(id, auditID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		versions[i] = entry
+	}
+
+	changes, err := data.DiffSnapshots(versions[0].After, versions[1].After)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.respondOK(w, r, envelope{"changes": changes})
+}
+
+// bookCitationHandler handles GET /v1/books/:id/citation.
+// It returns the book formatted as a reference in the style query parameter
+// (apa by default, or mla), e.g. {"citation": "Title. (2020). Publisher."}.
+func (app *applicationDependencies) bookCitationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	style := strings.ToLower(app.readString(r.URL.Query(), "style", data.CitationAPA))
 
+	v := validator.New()
+	v.Check(validator.In(style, data.CitationStyles...), "style", "must be one of "+strings.Join(data.CitationStyles, ", "))
 	if !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	book, err := app.models.Books.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
+	app.respondOK(w, r, envelope{"citation": book.Citation(style)})
+}
+
+// listBooksHandler handles GET /v1/books.
+// It reads optional page, page_size, sort, q, shelf, language, created_since,
+// created_from, created_to, ids, and fields query parameters (sort accepts a comma-separated list such as
+// "title,-publication_year"), validates them, and returns a paginated list of
+// books together with pagination metadata. With q the books whose title or
+// description match come back most relevant first, title matches weighing
+// more; sort then only breaks ties. page_size is capped at 100, or at
+// -privileged-max-page-size for callers with a valid X-API-Key.
+func (app *applicationDependencies) listBooksHandler(w http.ResponseWriter, r *http.Request) {
+	// readDate records parse failures on v, so create it before reading.
+	v := validator.New()
+
+	// Optional WHERE-clause filters, e.g. ?q=dragons for a catalogue search,
+	// ?shelf=A-12-3 for an inventory check, ?language=fr for books in French,
+	// ?created_since=2026-01-01 for recent additions (or created_from and
+	// created_to for a range, e.g. a monthly report), or ?ids=1,5,9 to fetch a
+	// known set in one query.
+	qs := r.URL.Query()
+	criteria := app.readBookCriteria(qs, v)
+
+	// With ids the whole set comes back on one page unless page_size is given.
+	defaultPageSize := 10
+	if len(criteria.IDs) > 0 {
+		defaultPageSize = len(criteria.IDs)
+	}
+
 	// Build the Filters value to pass to GetAll.
 	filters := data.Filters{
-		Page:     queryInput.Page,
-		PageSize: queryInput.PageSize,
-		Sort:     queryInput.Sort,
-		SortSafeList: []string{
-			"book_id", "title", "publication_year",
-			"-book_id", "-title", "-publication_year",
-		},
+		Page:         app.readInt(qs, "page", 1),
+		PageSize:     app.readInt(qs, "page_size", defaultPageSize),
+		Sort:         app.readBookSort(qs, v),
+		SortSafeList: bookSortSafeList,
+		DefaultSort:  app.config.defaultSort,
+		Columns:      app.readBookFields(qs, v), // nil = every column
+	}
+	timeFormat := app.readTimeFormat(r, v)
+
+	// --- Validation ---
+	v.Check(filters.Page > 0, "page", "must be greater than zero")
+	v.Check(filters.Page <= 10_000_000, "page", "must be a maximum of 10 million")
+	v.Check(filters.PageSize > 0, "page_size", "must be greater than zero")
+	app.checkPageSize(r, filters.PageSize, v)
+
+	// Guard the database against huge OFFSETs: Postgres still has to walk every
+	// skipped row, so deep pages get slower the further in they go.
+	offset := (filters.Page - 1) * filters.PageSize
+	v.Check(offset <= app.config.maxOffset, "page", "pagination too deep, use cursor pagination")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
 	}
 
-	books, metadata, err := app.models.Books.GetAll(filters)
+	// Pages beyond the public cap (privileged reports of up to 500 rows) are
+	// streamed so the slice and its marshaled bytes are never both in memory.
+	// That path has no XML form, always has an envelope (its metadata is only
+	// known after the books are written), and skips the ETag check.
+	if filters.PageSize > publicMaxPageSize && !prefersXML(r) && app.wantsEnvelope(r) {
+		app.streamBooks(w, r, criteria, filters, timeFormat)
+		return
+	}
+
+	books, metadata, err := app.models.Books.GetAll(criteria, filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
+	if len(books) == 0 {
+		beyond, lastPage, err := app.pageBeyondLast(criteria, filters)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if beyond {
+			app.pageOutOfRangeResponse(w, r, lastPage)
+			return
+		}
+	}
+
+	// Generic REST tooling reads pagination from headers, so they are sent
+	// alongside the metadata in the body (and replace it without an envelope).
+	app.setPaginationHeaders(w, r, metadata)
+
+	// Let polling clients skip unchanged pages with If-None-Match (see
+	// listETag). Last-Modified is informational only: the newest updated_at
+	// does not move when a book is deleted, so If-Modified-Since would answer
+	// 304 for a page and total_records that no longer exist.
+	// HTTP dates have one-second resolution, hence the truncation.
+	if len(books) > 0 {
+		lastModified := metadata.LastModified.UTC().Truncate(time.Second)
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	}
+	etag := listETag(books, metadata)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	// With ?fields the unselected columns are zero values, so only the
+	// requested ones are returned rather than misleading blanks.
+	var body any = books
+	if len(filters.Columns) > 0 {
+		projected := make([]map[string]any, len(books))
+		for i, book := range books {
+			projected[i] = book.Project(filters.Columns)
+		}
+		body = projected
+	}
+	body = withTimeFormat(body, timeFormat)
+
+	// Without an envelope the pagination headers are all the metadata there is.
+	if !app.wantsEnvelope(r) {
+		app.respondBare(w, r, body)
+		return
+	}
 
 	// Include both the books and the pagination metadata in the response envelope.
-	err = app.writeJSON(w, http.StatusOK, envelope{"books": books, "metadata": metadata}, nil)
+	app.respondOK(w, r, envelope{app.envelopeKey("books"): body, "metadata": metadata})
+}
+
+// pageBeyondLast is the -strict-pagination check for a list page that came
+// back empty. It reports whether the requested page lies past the last page
+// of a non-empty result, and which page that is. An empty page carries no
+// total (the window count has no row to ride on), so the matching books are
+// counted separately; this only happens for empty pages in strict mode.
+func (app *applicationDependencies) pageBeyondLast(criteria data.BookCriteria, filters data.Filters) (bool, int, error) {
+	if !app.config.strictPagination || filters.Page <= 1 {
+		return false, 0, nil
+	}
+	total, err := app.models.Books.Count(criteria)
+	if err != nil || total == 0 {
+		return false, 0, err
+	}
+	lastPage := (total + filters.PageSize - 1) / filters.PageSize
+	return filters.Page > lastPage, lastPage, nil
+}
+
+// readBookCriteria reads the q, shelf, language, created_since, created_from,
+// created_to, and ids filters shared by GET /v1/books and
+// GET /v1/books/export.ndjson, recording any problems on v. created_from is
+// another name for created_since, so a range reads naturally; sending both is
+// rejected. A created_to given as a plain date covers that whole day, so
+// created_from=2026-10-01 with created_to=2026-10-31 is all of October.
+func (app *applicationDependencies) readBookCriteria(qs url.Values, v *validator.Validator) data.BookCriteria {
+	sinceKey := "created_since"
+	if qs.Has("created_from") {
+		sinceKey = "created_from"
+	}
+
+	criteria := data.BookCriteria{
+		Shelf:        app.readString(qs, "shelf", ""),
+		Language:     strings.ToLower(app.readString(qs, "language", "")),
+		CreatedSince: app.readDate(qs, sinceKey, time.Time{}, v), // zero time = no filter
+		CreatedTo:    app.readDate(qs, "created_to", time.Time{}, v),
+		IDs:          app.readIDList(qs, "ids", v),
+		Query:        app.readString(qs, "q", ""),
+	}
+	if len(qs.Get("created_to")) == len(time.DateOnly) && !criteria.CreatedTo.IsZero() {
+		// Postgres stores microseconds, so this is the last instant of the day.
+		criteria.CreatedTo = criteria.CreatedTo.AddDate(0, 0, 1).Add(-time.Microsecond)
+	}
+
+	v.Check(!qs.Has("q") || criteria.Query != "", "q", "must be provided")
+	v.Check(len(criteria.Query) <= 200, "q", "must not be more than 200 characters long")
+	v.Check(criteria.Shelf == "" || validator.Matches(criteria.Shelf, validator.ShelfLocationRX),
+		"shelf", "must be in the form A-12-3")
+	v.Check(criteria.Language == "" || validator.IsLanguageCode(criteria.Language), "language", "must be a two-letter ISO 639-1 code")
+	v.Check(!qs.Has("created_since") || !qs.Has("created_from"), "created_from", "must not be used together with created_since")
+	v.Check(criteria.CreatedSince.IsZero() || criteria.CreatedTo.IsZero() || !criteria.CreatedSince.After(criteria.CreatedTo),
+		sinceKey, "must not be after created_to")
+	v.Check(!qs.Has("ids") || len(criteria.IDs) > 0, "ids", "must not be empty")
+	v.Check(len(criteria.IDs) <= maxIDsPerList, "ids", fmt.Sprintf("must not contain more than %d values", maxIDsPerList))
+	return criteria
+}
+
+// readBookSort reads the sort parameter (default -default-sort) for the book
+// list endpoints. It may list several fields, e.g. "title,-publication_year";
+// every one of them must be in the safe list or the whole request fails.
+func (app *applicationDependencies) readBookSort(qs url.Values, v *validator.Validator) string {
+	sort := app.readString(qs, "sort", app.config.defaultSort)
+
+	sortFields := strings.Split(sort, ",")
+	for _, field := range sortFields {
+		v.Check(validator.In(field, bookSortSafeList...), "sort", "invalid sort value")
+	}
+	v.Check(validator.Unique(sortFields), "sort", "must not contain duplicate values")
+	return sort
+}
+
+// readBookFields reads the fields projection for the book list endpoints,
+// returning nil (every column) when it is absent.
+func (app *applicationDependencies) readBookFields(qs url.Values, v *validator.Validator) []string {
+	var fields []string
+	if value := app.readString(qs, "fields", ""); value != "" {
+		fields = strings.Split(value, ",")
+	}
+
+	for _, field := range fields {
+		v.Check(validator.In(field, data.BookColumns...), "fields", "invalid field name")
+	}
+	v.Check(validator.Unique(fields), "fields", "must not contain duplicate values")
+	return fields
+}
+
+// searchBooksHandler handles GET /v1/books/search.
+// It combines the optional title, publisher, year_from, year_to, and age
+// filters with page/page_size pagination. When title is given the results
+// are ranked by full-text relevance; otherwise they are ordered by book_id.
+// page_size has the same caps as GET /v1/books (see checkPageSize).
+// case_sensitive=true swaps the full-text title match for an exact-case
+// substring match for precise lookups.
+func (app *applicationDependencies) searchBooksHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	filters := data.SearchFilters{
+		Title:     app.readString(qs, "title", ""),
+		Publisher: app.readString(qs, "publisher", ""),
+		YearFrom:  app.readInt(qs, "year_from", 0),
+		YearTo:    app.readInt(qs, "year_to", 0),
+		Filters: data.Filters{
+			Page:     app.readInt(qs, "page", 1),
+			PageSize: app.readInt(qs, "page_size", 10),
+		},
+	}
+
+	// age is optional, and 0 is a meaningful value, so only set it when present.
+	if qs.Has("age") {
+		age := app.readInt(qs, "age", -1)
+		filters.ReaderAge = &age
+	}
+
+	// --- Validation ---
+	v := validator.New()
+	filters.CaseSensitive = app.readBool(qs, "case_sensitive", false, v)
+	v.Check(len(filters.Title) <= 200, "title", "must not be more than 200 characters long")
+	v.Check(len(filters.Publisher) <= 150, "publisher", "must not be more than 150 characters long")
+	v.Check(filters.YearFrom >= 0, "year_from", "must be zero or greater")
+	v.Check(filters.YearFrom <= app.currentYear(), "year_from", "must not be in the future")
+	v.Check(filters.YearTo >= 0, "year_to", "must be zero or greater")
+	v.Check(filters.YearTo <= app.currentYear(), "year_to", "must not be in the future")
+	v.Check(filters.YearFrom == 0 || filters.YearTo == 0 || filters.YearFrom <= filters.YearTo,
+		"year_from", "must not be after year_to")
+	if filters.ReaderAge != nil {
+		v.Check(*filters.ReaderAge >= 0, "age", "must be zero or greater")
+	}
+	v.Check(filters.Page > 0, "page", "must be greater than zero")
+	v.Check(filters.Page <= 10_000_000, "page", "must be a maximum of 10 million")
+	v.Check(filters.PageSize > 0, "page_size", "must be greater than zero")
+	app.checkPageSize(r, filters.PageSize, v)
+
+	offset := (filters.Page - 1) * filters.PageSize
+	v.Check(offset <= app.config.maxOffset, "page", "pagination too deep, use cursor pagination")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	books, metadata, err := app.models.Books.SearchBooks(filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !app.wantsEnvelope(r) {
+		app.setPaginationHeaders(w, r, metadata)
+		app.respondBare(w, r, books)
+		return
+	}
+	app.respondOK(w, r, envelope{app.envelopeKey("books"): books, "metadata": metadata})
+}
+
+// randomBookHandler handles GET /v1/books/random.
+// It returns one book picked at random, for "surprise me" features. The
+// optional q, shelf, language, created_*, and ids filters of GET /v1/books
+// narrow the pick, e.g. ?language=fr for a random French book. When nothing
+// matches the response is a 404.
+func (app *applicationDependencies) randomBookHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	criteria := app.readBookCriteria(r.URL.Query(), v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	book, err := app.models.Books.Random(criteria)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Every request should get a fresh pick, so keep caches out of it.
+	w.Header().Set("Cache-Control", "no-store")
+	if !app.wantsEnvelope(r) {
+		app.respondBare(w, r, book)
+		return
 	}
+	app.respondOK(w, r, envelope{app.envelopeKey("book"): book})
+}
+
+// recentBooksHandler handles GET /v1/books/recent.
+// It returns the newest books (by created_at) for "new arrivals" widgets.
+// The optional limit query parameter defaults to 10 and must be 1–50.
+func (app *applicationDependencies) recentBooksHandler(w http.ResponseWriter, r *http.Request) {
+	limit := app.readInt(r.URL.Query(), "limit", 10)
+
+	v := validator.New()
+	v.Check(limit >= 1, "limit", "must be at least 1")
+	v.Check(limit <= 50, "limit", "must be a maximum of 50")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+
+	books, err := app.models.Books.Recent(limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !app.wantsEnvelope(r) {
+		app.respondBare(w, r, books)
+		return
+	}
+	app.respondOK(w, r, envelope{app.envelopeKey("books"): books})
 }
 
 // replaceBookHandler handles PUT /v1/books/:id.
@@ -171,29 +720,14 @@ func (app *applicationDependencies) replaceBookHandler(w http.ResponseWriter, r
 		}
 		return
 	}
+	before := *book // Snapshot for the audit log; book is modified in place below.
 
 	// Decode the complete replacement body. We reuse CreateBookInput because
 	// PUT requires every field to be provided (same required fields as a create).
 	var input data.CreateBookInput
 	err = app.readJSON(w, r, &input)
 	if err != nil {
-		app.badRequestResponse(w, r, err)
-		return
-	}
-
-	// --- Validation: all fields are required for a full replacement ---
-	v := validator.New()
-	v.Check(input.Title != "", "title", "must be provided")
-	v.Check(len(input.Title) <= 255, "title", "must not be more than 255 characters long")
-	v.Check(input.ISBN != "", "isbn", "must be provided")
-	v.Check(len(input.ISBN) == 13, "isbn", "must be exactly 13 characters long")
-	v.Check(input.Publisher != "", "publisher", "must be provided")
-	v.Check(input.PublicationYear > 0, "publication_year", "must be provided")
-	v.Check(input.PublicationYear <= 2026, "publication_year", "must not be in the future")
-	v.Check(input.MinimumAge >= 0, "minimum_age", "must be zero or greater")
-
-	if !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.readJSONErrorResponse(w, r, err)
 		return
 	}
 
@@ -204,24 +738,54 @@ func (app *applicationDependencies) replaceBookHandler(w http.ResponseWriter, r
 	book.PublicationYear = input.PublicationYear
 	book.MinimumAge = input.MinimumAge
 	book.Description = input.Description
+	book.ShelfLocation = input.ShelfLocation
+	book.Language = strings.ToLower(input.Language)
+	book.Copies = data.DefaultCopies
+	if input.Copies != nil {
+		book.Copies = *input.Copies
+	}
 
-	// Persist the replaced book.
-	err = app.models.Books.Update(book)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
+	// --- Validation: all fields are required for a full replacement ---
+	// A replacement may not sneak past -immutable-fields either.
+	v := validator.New()
+	app.validateBook(v, book)
+	app.checkBookSchema(input, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
+		return
+	}
+	app.checkImmutable(&before, book, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
-	// Respond with the fully-replaced book.
-	err = app.writeJSON(w, http.StatusOK, envelope{"book": book}, nil)
+	// Persist the replaced book, together with its audit entry.
+	err = app.models.WithTx(func(tx data.Models) error {
+		if err := tx.Books.Update(book); err != nil {
+			return err
+		}
+		return tx.Audit.Record(data.AuditUpdate, book.ID, app.contextGetActor(r), &before, book)
+	})
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrDuplicateISBN):
+			app.duplicateISBNResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
 	}
+
+	// Respond with the fully-replaced book.
+	app.respondOK(w, r, envelope{app.envelopeKey("book"): book})
 }
 
 // updateBookHandler handles PATCH /v1/books/:id.
 // It fetches the existing record with Get(id), applies only the non-nil input
-// fields, validates the result, and saves the changes with Update().
+// fields, validates the result, and saves the changes with Update(). With
+// Content-Type: application/merge-patch+json the body is a JSON Merge Patch
+// (RFC 7396), where null clears description, shelf_location, or language.
 func (app *applicationDependencies) updateBookHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract and validate the :id URL parameter.
 	id, err := app.readIDParam(r)
@@ -241,12 +805,20 @@ func (app *applicationDependencies) updateBookHandler(w http.ResponseWriter, r *
 		}
 		return
 	}
+	before := *book // Snapshot for the audit log; book is modified in place below.
 
-	// Decode the partial update from the request body.
+	// Decode the partial update from the request body. A JSON Merge Patch
+	// decodes to the same pointer fields, with null already applied.
 	var input data.UpdateBookInput
-	err = app.readJSON(w, r, &input)
+	if isMergePatch(r) {
+		var patch data.MergePatchBookInput
+		err = app.readJSON(w, r, &patch)
+		input = patch.UpdateBookInput
+	} else {
+		err = app.readJSON(w, r, &input)
+	}
 	if err != nil {
-		app.badRequestResponse(w, r, err)
+		app.readJSONErrorResponse(w, r, err)
 		return
 	}
 
@@ -269,35 +841,45 @@ func (app *applicationDependencies) updateBookHandler(w http.ResponseWriter, r *
 	if input.Description != nil {
 		book.Description = *input.Description
 	}
+	if input.ShelfLocation != nil {
+		book.ShelfLocation = *input.ShelfLocation
+	}
+	if input.Language != nil {
+		book.Language = strings.ToLower(*input.Language)
+	}
+	if input.Copies != nil {
+		book.Copies = *input.Copies
+	}
 
 	// --- Validation on the merged (existing + updated) values ---
 	v := validator.New()
-	v.Check(book.Title != "", "title", "must be provided")
-	v.Check(len(book.Title) <= 255, "title", "must not be more than 255 characters long")
-	v.Check(book.ISBN != "", "isbn", "must be provided")
-	v.Check(len(book.ISBN) == 13, "isbn", "must be exactly 13 characters long")
-	v.Check(book.Publisher != "", "publisher", "must be provided")
-	v.Check(book.PublicationYear > 0, "publication_year", "must be provided")
-	v.Check(book.PublicationYear <= 2026, "publication_year", "must not be in the future")
-	v.Check(book.MinimumAge >= 0, "minimum_age", "must be zero or greater")
+	app.validateBook(v, book)
+	app.checkImmutable(&before, book, v)
 
 	if !v.Valid() {
-		app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v)
 		return
 	}
 
-	// Persist the changes.
-	err = app.models.Books.Update(book)
+	// Persist the changes, together with its audit entry.
+	err = app.models.WithTx(func(tx data.Models) error {
+		if err := tx.Books.Update(book); err != nil {
+			return err
+		}
+		return tx.Audit.Record(data.AuditUpdate, book.ID, app.contextGetActor(r), &before, book)
+	})
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrDuplicateISBN):
+			app.duplicateISBNResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
 	// Respond with the updated book.
-	err = app.writeJSON(w, http.StatusOK, envelope{"book": book}, nil)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-	}
+	app.respondOK(w, r, envelope{app.envelopeKey("book"): book})
 }
 
 // deleteBookHandler handles DELETE /v1/books/:id.
@@ -311,7 +893,17 @@ func (app *applicationDependencies) deleteBookHandler(w http.ResponseWriter, r *
 		return
 	}
 
-	err = app.models.Books.Delete(id)
+	// Read the book before deleting it so the audit entry has the old state.
+	err = app.models.WithTx(func(tx data.Models) error {
+		book, err := tx.Books.Get(id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Books.Delete(id); err != nil {
+			return err
+		}
+		return tx.Audit.Record(data.AuditDelete, id, app.contextGetActor(r), book, nil)
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -322,8 +914,66 @@ func (app *applicationDependencies) deleteBookHandler(w http.ResponseWriter, r *
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "book successfully deleted"}, nil)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
+	// A delete has no resource to return, so with -envelope-key the message
+	// itself goes under the key, keeping every success body the same shape.
+	resp := envelope{"message": "book successfully deleted"}
+	if app.config.envelopeKey != "" {
+		resp = envelope{app.config.envelopeKey: resp}
+	}
+	app.respondOK(w, r, resp)
+}
+
+// validateBook records on v every problem with the writable fields of book,
+// as the create, replace, update, and bulk update handlers all require them:
+// the title limit is -max-title-length, and with -strict-isbn the ISBN must
+// fit the publication year (see checkISBNEra).
+func (app *applicationDependencies) validateBook(v *validator.Validator, book *data.Book) {
+	v.Check(book.Title != "", "title", "must be provided")
+	v.Check(len(book.Title) <= app.config.maxTitleLength, "title", fmt.Sprintf("must not be more than %d characters long", app.config.maxTitleLength))
+	v.Check(book.ISBN != "", "isbn", "must be provided")
+	v.Check(len(book.ISBN) == data.ISBNLength, "isbn", fmt.Sprintf("must be exactly %d characters long", data.ISBNLength))
+	v.Check(book.Publisher != "", "publisher", "must be provided")
+	v.Check(book.PublicationYear >= data.MinPublicationYear, "publication_year", "must be provided")
+	v.Check(book.PublicationYear <= app.currentYear(), "publication_year", "must not be in the future")
+	v.Check(book.MinimumAge >= data.MinMinimumAge, "minimum_age", "must be zero or greater")
+	v.Check(book.ShelfLocation == "" || validator.Matches(book.ShelfLocation, validator.ShelfLocationRX),
+		"shelf_location", "must be in the form A-12-3")
+	v.Check(book.Language == "" || validator.IsLanguageCode(book.Language), "language", "must be a two-letter ISO 639-1 code")
+	v.Check(book.Copies >= data.MinCopies, "copies", "must be zero or greater")
+	app.checkISBNEra(book.ISBN, book.PublicationYear, v)
+}
+
+// checkISBNEra is the -strict-isbn cross-field check. 979-prefixed ISBNs were
+// first issued in data.ISBN979FirstYear, so one on an older book is most
+// likely a typo in either field. It does nothing unless -strict-isbn is set,
+// because legacy catalogues can hold reissues recorded with their original
+// year.
+func (app *applicationDependencies) checkISBNEra(isbn string, publicationYear int, v *validator.Validator) {
+	if !app.config.strictISBN {
+		return
+	}
+	v.Check(!strings.HasPrefix(isbn, "979") || publicationYear >= data.ISBN979FirstYear, "publication_year",
+		fmt.Sprintf("must be %d or later for a 979-prefixed ISBN", data.ISBN979FirstYear))
+}
+
+// bookMutableFields are the book fields a write can change, by JSON name; the
+// rest are set by the database. -immutable-fields may list any of them.
+var bookMutableFields = []string{
+	"title", "isbn", "publisher", "publication_year", "minimum_age",
+	"description", "shelf_location", "language", "copies",
+}
+
+// checkImmutable records "cannot be modified" on v for every -immutable-fields
+// field whose value in after differs from before, e.g. {"isbn": "cannot be
+// modified"}. Sending the stored value again is not a change, so clients can
+// still send whole objects back, and an optional text field that was never
+// set (still "") may be filled in once.
+func (app *applicationDependencies) checkImmutable(before, after *data.Book, v *validator.Validator) {
+	if len(app.config.immutableFields) == 0 {
+		return
+	}
+	old, updated := before.Project(app.config.immutableFields), after.Project(app.config.immutableFields)
+	for _, field := range app.config.immutableFields {
+		v.Check(old[field] == updated[field] || old[field] == "", field, "cannot be modified")
 	}
 }
@@ -0,0 +1,121 @@
+// cmd/api/handlers_test.go
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aoideee/lab4-tyshadaniels/internal/data"
+	"github.com/aoideee/lab4-tyshadaniels/internal/validator"
+)
+
+func TestReadBookCriteriaCreatedFromIsAlias(t *testing.T) {
+	app := newTestApplication(t)
+	october := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, key := range []string{"created_since", "created_from"} {
+		t.Run(key, func(t *testing.T) {
+			v := validator.New()
+			criteria := app.readBookCriteria(url.Values{key: {"2026-10-01"}}, v)
+			if !v.Valid() {
+				t.Fatalf("unexpected errors: %v", v.Errors)
+			}
+			if !criteria.CreatedSince.Equal(october) {
+				t.Errorf("CreatedSince = %v; want %v", criteria.CreatedSince, october)
+			}
+		})
+	}
+
+	t.Run("both", func(t *testing.T) {
+		v := validator.New()
+		app.readBookCriteria(url.Values{"created_since": {"2026-10-01"}, "created_from": {"2026-09-01"}}, v)
+		if _, ok := v.Errors["created_from"]; !ok {
+			t.Errorf("errors = %v; want one for created_from", v.Errors)
+		}
+	})
+
+	t.Run("after created_to", func(t *testing.T) {
+		v := validator.New()
+		app.readBookCriteria(url.Values{"created_from": {"2026-10-02"}, "created_to": {"2026-10-01"}}, v)
+		if _, ok := v.Errors["created_from"]; !ok {
+			t.Errorf("errors = %v; want one for created_from", v.Errors)
+		}
+	})
+}
+
+func TestValidateBook(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.maxTitleLength = 10
+
+	valid := func() *data.Book {
+		return &data.Book{Title: "Dune", ISBN: "9780306406157", Publisher: "Chilton", PublicationYear: 1965, Copies: 1}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*data.Book)
+		field  string // "" = valid
+	}{
+		{"valid", func(*data.Book) {}, ""},
+		{"title at -max-title-length", func(b *data.Book) { b.Title = strings.Repeat("a", 10) }, ""},
+		{"title over -max-title-length", func(b *data.Book) { b.Title = strings.Repeat("a", 11) }, "title"},
+		{"short isbn", func(b *data.Book) { b.ISBN = "123" }, "isbn"},
+		{"no publisher", func(b *data.Book) { b.Publisher = "" }, "publisher"},
+		{"bad shelf", func(b *data.Book) { b.ShelfLocation = "shelf" }, "shelf_location"},
+		{"negative copies", func(b *data.Book) { b.Copies = -1 }, "copies"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := valid()
+			tt.modify(book)
+			v := validator.New()
+			app.validateBook(v, book)
+
+			if tt.field == "" {
+				if !v.Valid() {
+					t.Errorf("unexpected errors: %v", v.Errors)
+				}
+				return
+			}
+			if _, ok := v.Errors[tt.field]; !ok || len(v.Errors) != 1 {
+				t.Errorf("errors = %v; want only %s", v.Errors, tt.field)
+			}
+		})
+	}
+}
+
+func TestCheckPageSize(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.privilegedMaxPageSize = 500
+
+	tests := []struct {
+		name       string
+		privileged bool
+		pageSize   int
+		valid      bool
+	}{
+		{"public at cap", false, publicMaxPageSize, true},
+		{"public over cap", false, publicMaxPageSize + 1, false},
+		{"privileged over public cap", true, 500, true},
+		{"privileged over cap", true, 501, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/books/search", nil)
+			if tt.privileged {
+				r = app.contextSetPrivileged(r)
+			}
+			v := validator.New()
+			app.checkPageSize(r, tt.pageSize, v)
+			if v.Valid() != tt.valid {
+				t.Errorf("valid = %t; want %t (errors %v)", v.Valid(), tt.valid, v.Errors)
+			}
+		})
+	}
+}
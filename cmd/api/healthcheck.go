@@ -0,0 +1,74 @@
+// cmd/api/healthcheck.go
+// This file contains the healthcheck handler used by load balancers and
+// dashboards to see whether the API and its connection pool are healthy.
+package main
+
+import (
+	"net/http"
+)
+
+// healthcheckHandler handles GET /v1/healthcheck.
+// It reports the application status, environment, and version together with
+// the raw database/sql pool statistics. The status is "degraded" (and the
+// response a 503) once the pool shows signs of exhaustion, so alerting can
+// fire before requests start timing out:
+//
+//   - the pool made -health-max-wait-count or more queries wait for a
+//     connection during the last -db-ping-interval, as sampled by monitorDB
+//     (0 disables this check), or
+//   - in-use/max-open connections reaches -health-max-in-use-ratio (only
+//     when the pool has a MaxOpenConnections limit), or
+//   - the background monitor has seen -db-max-ping-failures pings in a row
+//     fail (see monitorDB).
+func (app *applicationDependencies) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
+	stats := app.db.Stats()
+
+	// WaitCount is a lifetime total, so only recent waits say anything about
+	// the pool now; a busy hour last week must not leave the instance degraded
+	// until it restarts. monitorDB keeps the count, so this handler only reads
+	// state and any number of probers get the same answer.
+	waits := app.recentWaits.Load()
+
+	status := "available"
+	code := http.StatusOK
+
+	if app.config.health.maxWaitCount > 0 && waits >= app.config.health.maxWaitCount {
+		status = "degraded"
+	}
+	if stats.MaxOpenConnections > 0 {
+		ratio := float64(stats.InUse) / float64(stats.MaxOpenConnections)
+		if ratio >= app.config.health.maxInUseRatio {
+			status = "degraded"
+		}
+	}
+	dbReachable := !app.dbUnhealthy.Load()
+	if !dbReachable {
+		status = "degraded"
+	}
+	if status == "degraded" {
+		code = http.StatusServiceUnavailable
+	}
+
+	data := envelope{
+		"status": status,
+		"system_info": map[string]string{
+			"environment": app.config.environment,
+			"version":     appVersion,
+		},
+		"db_stats": map[string]any{
+			"reachable":            dbReachable,
+			"max_open_connections": stats.MaxOpenConnections,
+			"open_connections":     stats.OpenConnections,
+			"in_use":               stats.InUse,
+			"idle":                 stats.Idle,
+			"wait_count":           stats.WaitCount,
+			"recent_waits":         waits,
+			"wait_duration_ms":     stats.WaitDuration.Milliseconds(),
+		},
+	}
+
+	err := app.writeResponse(w, r, code, data, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,49 @@
+// cmd/api/healthcheck_test.go
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	_ "github.com/lib/pq"
+)
+
+func TestHealthcheckIsRepeatable(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.health.maxWaitCount = 100
+	app.config.health.maxInUseRatio = 1
+
+	// sql.Open does not connect, and the handler only reads pool statistics.
+	db, err := sql.Open("postgres", "postgres://localhost/unused")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	app.db = db
+	app.recentWaits.Store(150) // As monitorDB would after a busy interval.
+
+	// Two probers polling back to back must both see the degraded pool.
+	for i := range 2 {
+		rr := httptest.NewRecorder()
+		app.healthcheckHandler(rr, httptest.NewRequest(http.MethodGet, "/v1/healthcheck", nil))
+
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("probe %d: status = %d; want %d", i+1, rr.Code, http.StatusServiceUnavailable)
+		}
+		var body struct {
+			Status  string `json:"status"`
+			DBStats struct {
+				RecentWaits int64 `json:"recent_waits"`
+			} `json:"db_stats"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Status != "degraded" || body.DBStats.RecentWaits != 150 {
+			t.Errorf("probe %d: status %q, recent_waits %d; want degraded, 150", i+1, body.Status, body.DBStats.RecentWaits)
+		}
+	}
+}
@@ -6,11 +6,19 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"hash/fnv"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
+	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/aoideee/lab4-tyshadaniels/internal/data"
+	"github.com/aoideee/lab4-tyshadaniels/internal/validator"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -20,16 +28,99 @@ import (
 type envelope map[string]any
 
 // readIDParam extracts and validates the ":id" URL parameter added by httprouter.
-// Returns an error if the value is missing, non-numeric, or less than 1.
+// It returns a distinct error for each way the value can be wrong so clients
+// can tell a typo from an out-of-range value: not a number, zero or negative,
+// or too large to fit in an int64.
 func (app *applicationDependencies) readIDParam(r *http.Request) (int64, error) {
 	params := httprouter.ParamsFromContext(r.Context())
 	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
-	if err != nil || id < 1 {
-		return 0, errors.New("invalid id parameter")
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return 0, errors.New("invalid id parameter: value is out of range")
+		}
+		return 0, errors.New("invalid id parameter: must be an integer")
+	}
+	if id < 1 {
+		return 0, errors.New("invalid id parameter: must be greater than zero")
 	}
 	return id, nil
 }
 
+// bookETag returns a strong ETag for book derived from its ID and the time
+// it was last modified, so it changes on every successful update.
+func bookETag(book *data.Book) string {
+	return fmt.Sprintf(`"%d-%d"`, book.ID, book.UpdatedAt.UnixNano())
+}
+
+// listETag returns a weak ETag for a page of books. It covers the total
+// number of matching books, their newest updated_at, and the IDs on this
+// page, so an insert, an update, or a delete anywhere in the result set
+// changes it. A delete changes the total even though the newest updated_at
+// stays put.
+func listETag(books []*data.Book, metadata data.Metadata) string {
+	h := fnv.New64a()
+	for _, book := range books {
+		h.Write(strconv.AppendInt(nil, book.ID, 10))
+		h.Write([]byte{','})
+	}
+	return fmt.Sprintf(`W/"%d-%d-%x"`, metadata.TotalRecords, metadata.LastModified.UnixNano(), h.Sum64())
+}
+
+// etagMatches reports whether an If-None-Match header value lists etag (or is
+// "*"). Comparison is weak, as RFC 9110 requires for If-None-Match, so a
+// W/ prefix on either side is ignored.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// headResponseWriter stands in for the real ResponseWriter while a GET
+// handler runs on behalf of a HEAD request. Headers pass straight through,
+// but the status is held back and the body is counted instead of sent.
+type headResponseWriter struct {
+	http.ResponseWriter
+	status int
+	length int
+}
+
+// WriteHeader records the status so it can be sent once the length is known.
+func (hw *headResponseWriter) WriteHeader(status int) {
+	hw.status = status
+}
+
+// Write discards b, keeping only its length for the Content-Length header.
+func (hw *headResponseWriter) Write(b []byte) (int, error) {
+	hw.length += len(b)
+	return len(b), nil
+}
+
+// Unwrap returns the real ResponseWriter, so http.ResponseController calls
+// such as SetWriteDeadline in exportBooksHandler reach it. Flushing through a
+// controller would send the headers early, so GET handlers used with headOnly
+// must not flush.
+func (hw *headResponseWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
+
+// headOnly adapts a GET handler to serve HEAD requests: next runs exactly as
+// it would for GET (same lookup, status, Content-Type, and ETag), then only
+// the headers plus the Content-Length of the body it would have sent go out.
+func (app *applicationDependencies) headOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hw := &headResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next(hw, r)
+
+		w.Header().Set("Content-Length", strconv.Itoa(hw.length))
+		w.WriteHeader(hw.status)
+	}
+}
+
 // readString reads a string query parameter from qs, returning defaultValue
 // if the key is absent or empty.
 func (app *applicationDependencies) readString(qs url.Values, key, defaultValue string) string {
@@ -54,10 +145,67 @@ func (app *applicationDependencies) readInt(qs url.Values, key string, defaultVa
 	return i
 }
 
-// writeJSON marshals data to indented JSON, applies any custom headers,
-// sets Content-Type to "application/json", writes the status code, and
-// streams the body to the client.
-func (app *applicationDependencies) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
+// readDate reads a date query parameter from qs, accepting either a full
+// RFC3339 timestamp or a plain "2006-01-02" date (midnight UTC). It returns
+// defaultValue if the key is absent. A malformed value is recorded on v and
+// defaultValue is returned.
+func (app *applicationDependencies) readDate(qs url.Values, key string, defaultValue time.Time, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t
+		}
+	}
+	v.AddError(key, "must be an RFC3339 timestamp or a YYYY-MM-DD date")
+	return defaultValue
+}
+
+// readBool reads a boolean query parameter from qs, accepting "true" or "1"
+// and "false" or "0" (case-insensitively). It returns defaultValue if the key
+// is absent or empty. Anything else is recorded on v and defaultValue is
+// returned, so every endpoint reports a bad flag the same way.
+func (app *applicationDependencies) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	switch strings.ToLower(qs.Get(key)) {
+	case "":
+		return defaultValue
+	case "true", "1":
+		return true
+	case "false", "0":
+		return false
+	}
+	v.AddError(key, "must be true, false, 1, or 0")
+	return defaultValue
+}
+
+// readIDList reads a comma-separated list of positive integer IDs from qs,
+// e.g. "1,5,9". It returns nil if the key is absent. Malformed or
+// non-positive entries are recorded on v.
+func (app *applicationDependencies) readIDList(qs url.Values, key string, v *validator.Validator) []int64 {
+	s := qs.Get(key)
+	if s == "" {
+		return nil
+	}
+
+	var ids []int64
+	for _, part := range strings.Split(s, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil || id < 1 {
+			v.AddError(key, "must be a comma-separated list of positive integers")
+			return nil
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// writeJSON marshals data (usually an envelope) to indented JSON, applies any
+// custom headers, sets Content-Type to "application/json", writes the status
+// code, and streams the body to the client.
+func (app *applicationDependencies) writeJSON(w http.ResponseWriter, status int, data any, headers http.Header) error {
 	js, err := json.MarshalIndent(data, "", "\t")
 	if err != nil {
 		return err
@@ -74,18 +222,98 @@ func (app *applicationDependencies) writeJSON(w http.ResponseWriter, status int,
 	return nil
 }
 
+// writeResponse writes data in the format the client asked for via the
+// Accept header: XML for application/xml or text/xml, JSON otherwise.
+// Handlers should call this (or respondOK/respondCreated) rather than
+// writeJSON directly so every endpoint supports both formats.
+func (app *applicationDependencies) writeResponse(w http.ResponseWriter, r *http.Request, status int, data envelope, headers http.Header) error {
+	// The body depends on Accept, so caches must key on it.
+	w.Header().Add("Vary", "Accept")
+
+	if prefersXML(r) {
+		return app.writeXML(w, status, data, headers)
+	}
+	return app.writeJSON(w, status, data, headers)
+}
+
+// prefersXML reports whether the Accept header ranks an XML media type above
+// JSON. Media ranges are compared by their q-value; on a tie the one listed
+// first wins, and wildcards count as JSON.
+func prefersXML(r *http.Request) bool {
+	bestQ, xmlWins := 0.0, false
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if name == "q" {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		var isXML bool
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case "application/xml", "text/xml":
+			isXML = true
+		case "application/json", "application/*", "*/*":
+			isXML = false
+		default:
+			continue
+		}
+
+		if q > bestQ {
+			bestQ, xmlWins = q, isXML
+		}
+	}
+	return xmlWins
+}
+
+// respondOK writes data with a 200 OK status. If the response cannot be
+// written it falls back to a 500, so handlers can finish with a single call.
+func (app *applicationDependencies) respondOK(w http.ResponseWriter, r *http.Request, data envelope) {
+	err := app.writeResponse(w, r, http.StatusOK, data, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// respondCreated writes data with a 201 Created status and any extra headers
+// (e.g. Location), falling back to a 500 if the response cannot be written.
+func (app *applicationDependencies) respondCreated(w http.ResponseWriter, r *http.Request, data envelope, headers http.Header) {
+	err := app.writeResponse(w, r, http.StatusCreated, data, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// defaultMaxBodyBytes is the readJSON size limit for routes that do not set
+// their own with limitBody.
+const defaultMaxBodyBytes = 1_048_576 // 1 MB
+
 // readJSON decodes a single JSON value from the request body into dst.
-// It enforces a 1 MB size limit, rejects unknown fields, and ensures the
-// body contains exactly one JSON value (no trailing data).
+// It enforces the route's size limit (see limitBody; 1 MB by default), rejects
+// unknown fields, and ensures the body contains exactly one JSON value (no
+// trailing data). A whole number too large for its integer field is reported
+// as a *data.FieldError ("is out of range") rather than a raw decode error.
 func (app *applicationDependencies) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
-	// Cap the request body to 1 MB to prevent large-payload attacks.
-	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+	// Cap the request body to prevent large-payload attacks.
+	r.Body = http.MaxBytesReader(w, r.Body, app.contextGetBodyLimit(r))
 
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields() // Reject fields not present in dst.
 
 	err := dec.Decode(dst)
 	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		var unmarshalTypeError *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		case errors.As(err, &unmarshalTypeError) && isIntegerOverflow(unmarshalTypeError):
+			return &data.FieldError{Field: unmarshalTypeError.Field, Message: "is out of range"}
+		}
 		return err
 	}
 
@@ -96,4 +324,27 @@ func (app *applicationDependencies) readJSON(w http.ResponseWriter, r *http.Requ
 	}
 
 	return nil
+}
+
+// isIntegerOverflow reports whether err is a whole-number literal that does
+// not fit the integer field it was decoded into, e.g. 99999999999999999999
+// for publication_year. Fractions such as 1.5 and non-numbers are not.
+func isIntegerOverflow(err *json.UnmarshalTypeError) bool {
+	literal, ok := strings.CutPrefix(err.Value, "number ")
+	if !ok || err.Field == "" || strings.ContainsAny(literal, ".eE") {
+		return false
+	}
+	switch err.Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// isMergePatch reports whether r's body is declared as a JSON Merge Patch
+// (RFC 7396) rather than plain JSON.
+func isMergePatch(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/merge-patch+json"
 }
\ No newline at end of file
@@ -0,0 +1,147 @@
+// cmd/api/helpers_test.go
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aoideee/lab4-tyshadaniels/internal/data"
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestReadIDParam(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name    string
+		id      string
+		want    int64
+		wantErr string
+	}{
+		{"valid", "42", 42, ""},
+		{"not a number", "abc", 0, "invalid id parameter: must be an integer"},
+		{"negative", "-5", 0, "invalid id parameter: must be greater than zero"},
+		{"zero", "0", 0, "invalid id parameter: must be greater than zero"},
+		{"larger than int64", "9223372036854775808", 0, "invalid id parameter: value is out of range"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := withParams(httptest.NewRequest("GET", "/v1/books/"+tt.id, nil), httprouter.Param{Key: "id", Value: tt.id})
+
+			got, err := app.readIDParam(r)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("readIDParam(%q) error = %v; want %q", tt.id, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readIDParam(%q) unexpected error: %v", tt.id, err)
+			}
+			if got != tt.want {
+				t.Errorf("readIDParam(%q) = %d; want %d", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadJSONIntegerOverflow(t *testing.T) {
+	app := newTestApplication(t)
+	tooBig := "99999999999999999999"
+
+	tests := []struct {
+		name  string
+		body  string
+		dst   func() any
+		field string
+	}{
+		{"create publication_year", `{"publication_year":` + tooBig + `}`, func() any { return &data.CreateBookInput{} }, "publication_year"},
+		{"create minimum_age", `{"minimum_age":` + tooBig + `}`, func() any { return &data.CreateBookInput{} }, "minimum_age"},
+		{"update publication_year", `{"publication_year":` + tooBig + `}`, func() any { return &data.UpdateBookInput{} }, "publication_year"},
+		{"update minimum_age", `{"minimum_age":-` + tooBig + `}`, func() any { return &data.UpdateBookInput{} }, "minimum_age"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/books", strings.NewReader(tt.body))
+			err := app.readJSON(httptest.NewRecorder(), r, tt.dst())
+
+			var fieldErr *data.FieldError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("readJSON error = %v; want a *data.FieldError", err)
+			}
+			if fieldErr.Field != tt.field || fieldErr.Message != "is out of range" {
+				t.Errorf("FieldError = %q %q; want %q %q", fieldErr.Field, fieldErr.Message, tt.field, "is out of range")
+			}
+		})
+	}
+}
+
+func TestReadJSONNonIntegerIsNotOverflow(t *testing.T) {
+	app := newTestApplication(t)
+
+	// A fraction is a type error, not an out-of-range value, and stays a 400.
+	r := httptest.NewRequest(http.MethodPatch, "/v1/books/1", strings.NewReader(`{"publication_year":1.5}`))
+	err := app.readJSON(httptest.NewRecorder(), r, &data.UpdateBookInput{})
+
+	var fieldErr *data.FieldError
+	if err == nil || errors.As(err, &fieldErr) {
+		t.Errorf("readJSON error = %v; want a plain decode error", err)
+	}
+}
+
+func TestReadJSONErrorResponseOverflowIs422(t *testing.T) {
+	app := newTestApplication(t)
+	r := httptest.NewRequest(http.MethodPost, "/v1/books", nil)
+	rr := httptest.NewRecorder()
+
+	app.readJSONErrorResponse(rr, r, &data.FieldError{Field: "publication_year", Message: "is out of range"})
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d; want %d", rr.Code, http.StatusUnprocessableEntity)
+	}
+	if !strings.Contains(rr.Body.String(), `"publication_year": "is out of range"`) {
+		t.Errorf("body = %s; want the publication_year error", rr.Body.String())
+	}
+}
+
+// deadlineRecorder is a ResponseRecorder that, like the server's own
+// ResponseWriter, supports write deadlines.
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	deadlineSet bool
+}
+
+func (d *deadlineRecorder) SetWriteDeadline(time.Time) error {
+	d.deadlineSet = true
+	return nil
+}
+
+func TestHeadOnlyReachesResponseController(t *testing.T) {
+	app := newTestApplication(t)
+	logs := captureLogs(app)
+
+	var deadlineErr error
+	next := func(w http.ResponseWriter, r *http.Request) {
+		deadlineErr = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		w.Write([]byte("body"))
+	}
+
+	rec := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+	app.headOnly(next)(rec, httptest.NewRequest(http.MethodHead, "/v1/books/export.ndjson", nil))
+
+	if deadlineErr != nil || !rec.deadlineSet {
+		t.Errorf("SetWriteDeadline through headOnly: err = %v, reached writer = %t", deadlineErr, rec.deadlineSet)
+	}
+	if got := rec.Header().Get("Content-Length"); got != "4" {
+		t.Errorf("Content-Length = %q; want %q", got, "4")
+	}
+	if rec.Body.Len() != 0 || logs.Len() != 0 {
+		t.Errorf("body %q, logs %q; want both empty", rec.Body.String(), logs.String())
+	}
+}
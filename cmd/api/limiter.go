@@ -0,0 +1,138 @@
+// cmd/api/limiter.go
+// This file contains the storage behind the rateLimit middleware.
+package main
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// LimiterStore decides whether the client identified by key may make another
+// request. When it may not, retryAfter says how long the client should wait.
+//
+// The default memoryLimiterStore keeps its buckets in process memory, so each
+// API instance limits clients independently. To share limits across several
+// instances, implement LimiterStore on top of a shared backend such as Redis
+// and assign it to applicationDependencies.limiter in main; rateLimit does not
+// need to change.
+type LimiterStore interface {
+	Allow(key string) (ok bool, retryAfter time.Duration)
+}
+
+// limiterDefaults are the per-client rate limits for each -env, used for
+// whichever of -limiter-rps and -limiter-burst is not given explicitly. An
+// rps of 0 means unlimited, so local development is never throttled.
+var limiterDefaults = map[string]struct {
+	rps   float64
+	burst int
+}{
+	"development": {rps: 0, burst: 0},
+	"staging":     {rps: 10, burst: 20},
+	"production":  {rps: 4, burst: 8},
+}
+
+// client holds a per-key rate limiter and the time it was last seen.
+// lastSeen lets us evict old entries so the map does not grow forever.
+type client struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// memoryLimiterStore is an in-process LimiterStore using one
+// golang.org/x/time/rate token bucket per key. A background goroutine removes,
+// every sweep interval, the entries that have not been seen for ttl.
+type memoryLimiterStore struct {
+	mu      sync.Mutex
+	clients map[string]*client
+	rps     rate.Limit
+	burst   int
+	ttl     time.Duration
+}
+
+// newMemoryLimiterStore returns a memoryLimiterStore allowing rps requests per
+// second with the given burst (any number when rps is 0), and starts its
+// cleanup goroutine, which runs every sweep and drops entries idle for longer
+// than ttl.
+func newMemoryLimiterStore(rps float64, burst int, sweep, ttl time.Duration) *memoryLimiterStore {
+	limit := rate.Limit(rps)
+	if rps == 0 {
+		limit = rate.Inf
+	}
+
+	store := &memoryLimiterStore{
+		clients: make(map[string]*client),
+		rps:     limit,
+		burst:   burst,
+		ttl:     ttl,
+	}
+
+	// Cleanup goroutine: remove stale entries every sweep interval.
+	go func() {
+		for {
+			time.Sleep(sweep)
+			store.mu.Lock()
+			for key, c := range store.clients {
+				if time.Since(c.lastSeen) > store.ttl {
+					delete(store.clients, key)
+				}
+			}
+			store.mu.Unlock()
+		}
+	}()
+
+	return store
+}
+
+// Allow consumes one token from key's bucket, creating the bucket on first use.
+func (s *memoryLimiterStore) Allow(key string) (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, found := s.clients[key]
+	if !found {
+		c = &client{limiter: rate.NewLimiter(s.rps, s.burst)}
+		s.clients[key] = c
+	}
+	c.lastSeen = time.Now()
+
+	// Reserve tells us how long the next token is away; an empty bucket is
+	// reported as a denial and the reservation is handed back.
+	reservation := c.limiter.Reserve()
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return false, delay
+	}
+	return true, 0
+}
+
+// limiterSnapshot is a point-in-time view of a LimiterStore for debugging.
+type limiterSnapshot struct {
+	TrackedClients int       `json:"tracked_clients" xml:"tracked_clients"` // Keys currently held in memory
+	Found          bool      `json:"found" xml:"found"`                     // Whether the requested key has a bucket
+	Tokens         float64   `json:"tokens" xml:"tokens"`                   // Tokens left in the key's bucket right now
+	LastSeen       time.Time `json:"last_seen" xml:"last_seen"`             // When the key last made a request
+}
+
+// limiterInspector is implemented by LimiterStores that can describe their
+// state for GET /debug/ratelimit. It is optional, so a shared-backend store
+// does not have to support it.
+type limiterInspector interface {
+	Inspect(key string) limiterSnapshot
+}
+
+// Inspect reports how many keys are tracked and, if key has a bucket, its
+// remaining tokens and last-seen time. It reads under the same mutex as Allow.
+func (s *memoryLimiterStore) Inspect(key string) limiterSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := limiterSnapshot{TrackedClients: len(s.clients)}
+	if c, found := s.clients[key]; found {
+		snapshot.Found = true
+		snapshot.Tokens = c.limiter.Tokens()
+		snapshot.LastSeen = c.lastSeen.UTC()
+	}
+	return snapshot
+}
@@ -4,13 +4,16 @@
 package main
 
 import (
+	"crypto/subtle"
 	"fmt"
+	"log/slog"
+	"math/rand/v2"
 	"net"
 	"net/http"
-	"sync"
+	"runtime/debug"
+	"slices"
+	"strings"
 	"time"
-
-	"golang.org/x/time/rate"
 )
 
 // recoverPanic catches any runtime panic that occurs in a downstream handler.
@@ -22,72 +25,231 @@ func (app *applicationDependencies) recoverPanic(next http.Handler) http.Handler
 		// defer runs when the surrounding goroutine unwinds, even after a panic.
 		defer func() {
 			if err := recover(); err != nil {
+				// Capture the stack now, while we are still inside the panicking
+				// goroutine, so the log points at the offending line.
+				stack := debug.Stack()
+				panicErr := fmt.Errorf("%s", err)
+				app.logError(r, panicErr, slog.String("stack", string(stack)))
+
 				// Tell the HTTP server to close the connection after this response.
 				w.Header().Set("Connection", "close")
-				// Convert the recovered panic value to an error and send a 500.
-				app.serverErrorResponse(w, r, fmt.Errorf("%s", err))
+				// Send a clean 500; the stack only ever goes to the log.
+				app.errorResponse(w, r, http.StatusInternalServerError, app.serverErrorMessage(panicErr))
 			}
 		}()
 		next.ServeHTTP(w, r)
 	})
 }
 
-// client holds a per-IP rate limiter and the time it was last seen.
-// lastSeen lets us evict old entries so the map does not grow forever.
-type client struct {
-	limiter  *rate.Limiter
-	lastSeen time.Time
+// maintenanceRetryAfterSeconds is the Retry-After value sent with maintenance-mode 503s.
+const maintenanceRetryAfterSeconds = 120
+
+// readOnlyMode rejects POST, PUT, PATCH, and DELETE requests with a 405 when
+// the server runs with -read-only, e.g. for disaster recovery against a read
+// replica. Unlike maintenanceMode, which is a temporary state signalled with
+// a retryable 503, read-only is how this deployment always works, so clients
+// should not retry the write here. Without -read-only next is returned
+// unwrapped.
+func (app *applicationDependencies) readOnlyMode(next http.Handler) http.Handler {
+	if !app.config.readOnly {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+		default:
+			app.readOnlyModeResponse(w, r)
+		}
+	})
 }
 
-// rateLimit implements per-IP token-bucket rate limiting using the
-// golang.org/x/time/rate package. Each unique IP gets its own limiter
-// seeded with 2 tokens per second and a burst capacity of 4.
-// A background goroutine cleans up entries that have not been seen in 3 minutes.
-func (app *applicationDependencies) rateLimit(next http.Handler) http.Handler {
-	// clients maps IP addresses to their individual rate limiters.
-	var (
-		mu      sync.Mutex
-		clients = make(map[string]*client)
-	)
-
-	// Cleanup goroutine: remove stale IP entries every minute.
-	go func() {
-		for {
-			time.Sleep(time.Minute)
-			mu.Lock()
-			for ip, c := range clients {
-				if time.Since(c.lastSeen) > 3*time.Minute {
-					delete(clients, ip)
-				}
+// busyRetryAfterSeconds is the Retry-After value sent when limitConcurrency
+// turns a request away; in-flight requests usually finish within a second.
+const busyRetryAfterSeconds = 1
+
+// databaseRetryAfterSeconds is the Retry-After value sent when PostgreSQL is
+// refusing connections; a full pool or a restart takes a few seconds to clear.
+const databaseRetryAfterSeconds = 5
+
+// maintenanceMode rejects POST, PUT, PATCH, and DELETE requests with a 503
+// while app.maintenance is set, so a deploy can proceed without writes.
+// Safe methods (GET, HEAD, OPTIONS) are always let through, and so is
+// PUT /v1/admin/maintenance, which is how maintenance is ended.
+func (app *applicationDependencies) maintenanceMode(next http.Handler) http.Handler {
+	togglePath := app.config.basePath + "/v1/admin/maintenance"
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.maintenance.Load() && r.URL.Path != togglePath {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				app.maintenanceModeResponse(w, r)
+				return
 			}
-			mu.Unlock()
 		}
-	}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setMaintenance turns maintenance mode on or off and logs the change, so
+// operators can tell from the logs when writes started and stopped being
+// rejected. by names who asked: the -maintenance flag or an audit actor
+// (see contextGetActor). Setting the current state again logs nothing.
+func (app *applicationDependencies) setMaintenance(enabled bool, by string) {
+	if app.maintenance.Swap(enabled) == enabled {
+		return
+	}
+	if enabled {
+		app.logger.Info("maintenance mode enabled: write requests will be rejected with 503", "by", by)
+		return
+	}
+	app.logger.Info("maintenance mode disabled: write requests are accepted again", "by", by)
+}
+
+// checkHost rejects requests whose Host header is not in -allowed-hosts with a
+// 400, since absolute URLs such as the Atom feed's links are built from it
+// when -public-url is not set. An entry
+// without a port matches that host on any port. The healthcheck is exempt so
+// load balancers can probe instances by IP. With no allowed hosts configured
+// next is returned unwrapped.
+func (app *applicationDependencies) checkHost(next http.Handler) http.Handler {
+	if len(app.config.allowedHosts) == 0 {
+		return next
+	}
 
+	healthPath := app.config.basePath + "/v1/healthcheck"
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Extract just the IP from the RemoteAddr (strips the port).
-		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if r.URL.Path == healthPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		host := strings.ToLower(r.Host)
+		hostname, _, err := net.SplitHostPort(host)
 		if err != nil {
-			app.serverErrorResponse(w, r, err)
+			hostname = host // No port in the header.
+		}
+
+		if !slices.Contains(app.config.allowedHosts, host) && !slices.Contains(app.config.allowedHosts, hostname) {
+			app.badRequestResponse(w, r, fmt.Errorf("host %q is not allowed", r.Host))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate checks the X-API-Key header against -api-keys. A matching key
+// marks the request as privileged in its context (see contextIsPrivileged)
+// and names it as the audit actor (see contextSetActor);
+// an unknown key is rejected with 401 rather than silently downgraded, so a
+// misconfigured internal tool notices. Requests without the header pass
+// through as ordinary public callers.
+func (app *applicationDependencies) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-API-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
 			return
 		}
 
-		mu.Lock()
-		// Create a new limiter for this IP if we have not seen it before.
-		if _, found := clients[ip]; !found {
-			clients[ip] = &client{
-				limiter: rate.NewLimiter(rate.Limit(2), 4), // 2 req/s, burst of 4
+		for _, known := range app.config.apiKeys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(known)) == 1 {
+				next.ServeHTTP(w, app.contextSetActor(app.contextSetPrivileged(r), key))
+				return
 			}
 		}
-		clients[ip].lastSeen = time.Now()
+		app.invalidAPIKeyResponse(w, r)
+	})
+}
+
+// limitBody sets the largest request body readJSON accepts for one route, so
+// e.g. a batch endpoint can allow more than the 1 MB defaultMaxBodyBytes while
+// single-resource writes stay small. It is applied at route registration.
+func (app *applicationDependencies) limitBody(maxBytes int64, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next(w, app.contextSetBodyLimit(r, maxBytes))
+	}
+}
+
+// strictTransportSecurity tells browsers to use HTTPS for all future requests.
+// The header is only sent in production when the server itself terminates TLS;
+// otherwise next is returned unwrapped.
+func (app *applicationDependencies) strictTransportSecurity(next http.Handler) http.Handler {
+	if app.config.environment != "production" || !app.config.tlsEnabled() {
+		return next
+	}
+
+	value := fmt.Sprintf("max-age=%d", int(app.config.tls.hstsMaxAge.Seconds()))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", value)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitConcurrency caps the number of requests being handled at once at
+// -max-concurrent-requests, as coarse backpressure that protects the database
+// whichever clients the load comes from. A request arriving when every slot
+// is taken gets a 503 straight away rather than queueing. With no limit set
+// next is returned unwrapped.
+func (app *applicationDependencies) limitConcurrency(next http.Handler) http.Handler {
+	if app.config.maxConcurrentRequests == 0 {
+		return next
+	}
+
+	slots := make(chan struct{}, app.config.maxConcurrentRequests)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+			next.ServeHTTP(w, r)
+		default:
+			app.serverBusyResponse(w, r)
+		}
+	})
+}
+
+// chaosDelay holds back -chaos-delay-percent of requests for a random time
+// of up to -chaos-delay before handling them, so client timeout, retry, and
+// backoff behaviour can be tested against this API. main only allows it in
+// development. A client that gives up during the delay ends it early. With
+// -chaos-delay unset next is returned unwrapped.
+func (app *applicationDependencies) chaosDelay(next http.Handler) http.Handler {
+	if app.config.chaos.delay == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rand.IntN(100) < app.config.chaos.delayPercent {
+			select {
+			case <-time.After(rand.N(app.config.chaos.delay)):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimit implements per-IP rate limiting. The decision is delegated to
+// app.limiter (see LimiterStore); by default that is an in-memory token bucket
+// per IP refilled at -limiter-rps with a capacity of -limiter-burst. Flags
+// left unset take their value for -env from limiterDefaults: no limit in
+// development, 10 per second with a burst of 20 in staging, and 4 per second
+// with a burst of 8 in production.
+func (app *applicationDependencies) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Extract just the IP from the RemoteAddr (strips the port).
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
 
-		// Allow() consumes one token; returns false if the bucket is empty.
-		if !clients[ip].limiter.Allow() {
-			mu.Unlock()
-			app.rateLimitExceededResponse(w, r)
+		if ok, retryAfter := app.limiter.Allow(ip); !ok {
+			app.rateLimitExceededResponse(w, r, retryAfter)
 			return
 		}
-		mu.Unlock()
 
 		next.ServeHTTP(w, r)
 	})
@@ -0,0 +1,112 @@
+// cmd/api/middleware_test.go
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecoverPanic(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.environment = "production"
+	logs := captureLogs(app)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	rr := httptest.NewRecorder()
+	app.recoverPanic(next).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/books/1", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d; want %d", rr.Code, http.StatusInternalServerError)
+	}
+	if got := rr.Header().Get("Connection"); got != "close" {
+		t.Errorf("Connection = %q; want %q", got, "close")
+	}
+	if body := rr.Body.String(); strings.Contains(body, "goroutine") || strings.Contains(body, "boom") {
+		t.Errorf("response leaks panic details: %s", body)
+	}
+
+	var entry struct {
+		Level string `json:"level"`
+		Msg   string `json:"msg"`
+		Stack string `json:"stack"`
+	}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("decoding log entry %q: %v", logs.String(), err)
+	}
+	if entry.Level != "ERROR" || entry.Msg != "boom" {
+		t.Errorf("logged %s %q; want ERROR %q", entry.Level, entry.Msg, "boom")
+	}
+	// The stack must point at the panicking handler, not just at recoverPanic.
+	if !strings.Contains(entry.Stack, "middleware_test.go") {
+		t.Errorf("stack attribute does not include the panicking handler:\n%s", entry.Stack)
+	}
+}
+
+func TestSetMaintenanceLogsTransitions(t *testing.T) {
+	app := newTestApplication(t)
+	logs := captureLogs(app)
+
+	app.setMaintenance(true, "-maintenance flag")
+	app.setMaintenance(true, "api-key:1a2b3c4d") // Already on: nothing to log.
+	app.setMaintenance(false, "api-key:1a2b3c4d")
+
+	type entry struct {
+		Level string `json:"level"`
+		Msg   string `json:"msg"`
+		By    string `json:"by"`
+	}
+	var got []entry
+	dec := json.NewDecoder(logs)
+	for dec.More() {
+		var e entry
+		if err := dec.Decode(&e); err != nil {
+			t.Fatalf("decoding log entry: %v", err)
+		}
+		got = append(got, e)
+	}
+
+	want := []entry{
+		{"INFO", "maintenance mode enabled: write requests will be rejected with 503", "-maintenance flag"},
+		{"INFO", "maintenance mode disabled: write requests are accepted again", "api-key:1a2b3c4d"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("logged %d entries; want %d: %s", len(got), len(want), logs.String())
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %+v; want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMaintenanceModeAllowsToggle(t *testing.T) {
+	app := newTestApplication(t)
+	app.maintenance.Store(true)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := app.maintenanceMode(next)
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/v1/books", http.StatusNoContent},
+		{http.MethodPost, "/v1/books", http.StatusServiceUnavailable},
+		{http.MethodPut, "/v1/admin/maintenance", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+		if rr.Code != tt.want {
+			t.Errorf("%s %s: status = %d; want %d", tt.method, tt.path, rr.Code, tt.want)
+		}
+	}
+}
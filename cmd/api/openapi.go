@@ -0,0 +1,25 @@
+// cmd/api/openapi.go
+// This file serves the OpenAPI 3 description of the API. The document itself
+// is hand-maintained in openapi.json and embedded into the binary at build time,
+// so it must be updated alongside any change to routes, parameters, or the
+// Book and input structs.
+package main
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec holds the raw bytes of openapi.json.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// openAPIHandler handles GET /v1/openapi.json.
+// It writes the embedded document as-is; no envelope is added because
+// client-SDK generators expect the bare specification.
+func (app *applicationDependencies) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPISpec)
+}
@@ -0,0 +1,26 @@
+// cmd/api/root.go
+// This file contains the entry point served at GET /, so someone opening the
+// API root in a browser or with curl finds their way around instead of a 404.
+package main
+
+import (
+	"net/http"
+)
+
+// rootHandler handles GET / (or -base-path/).
+// It returns a small welcome document with links to the main resources,
+// HATEOAS-style, so clients can discover the API from its root. The links
+// include -base-path but not the host, so they stay valid behind a proxy.
+func (app *applicationDependencies) rootHandler(w http.ResponseWriter, r *http.Request) {
+	base := app.config.basePath
+	app.respondOK(w, r, envelope{
+		"message": "Welcome to the Community Library Management System API",
+		"version": appVersion,
+		"links": map[string]string{
+			"self":        base + "/",
+			"books":       base + "/v1/books",
+			"healthcheck": base + "/v1/healthcheck",
+			"openapi":     base + "/v1/openapi.json",
+		},
+	})
+}
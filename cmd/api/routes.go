@@ -7,20 +7,46 @@ import (
 	"github.com/julienschmidt/httprouter"
 )
 
+// maxBookBodyBytes is the body size limit for single-book writes. It is the
+// same as defaultMaxBodyBytes, and is defined from it so the two cannot drift
+// apart; it is named so the book routes can be given their own limit later.
+const maxBookBodyBytes = defaultMaxBodyBytes
+
 // routes registers all HTTP endpoints and returns the configured router wrapped
-// in the recoverPanic and rateLimit middlewares.
+// in the recoverPanic, checkHost, strictTransportSecurity, rateLimit,
+// limitConcurrency, authenticate, readOnlyMode, maintenanceMode, and chaosDelay
+// middlewares.
 //
 // Middleware chain (outermost → innermost):
 //
-//	recoverPanic → rateLimit → router
+//	recoverPanic → checkHost → strictTransportSecurity → rateLimit → limitConcurrency → authenticate → readOnlyMode → maintenanceMode → chaosDelay → router
 //
-// Current endpoints:
+// Current endpoints (each prefixed with -base-path when it is set):
 //
-//	POST   /v1/books        – create a new book
-//	GET    /v1/books/:id    – retrieve a single book by ID
-//	GET    /v1/books        – list all books (paginated)
-//	PATCH  /v1/books/:id    – partially update an existing book
-//	DELETE /v1/books/:id    – delete a book by ID
+//	POST   /v1/books               – create a new book
+//	GET    /v1/books/:id           – retrieve a single book by ID
+//	HEAD   /v1/books/:id           – same as GET but headers only (existence/ETag check)
+//	GET    /v1/books/isbn/:isbn    – retrieve a single book by ISBN
+//	GET    /v1/books/:id/history   – audit log of changes to a book
+//	GET    /v1/books/:id/citation  – the book as an APA or MLA reference
+//	GET    /v1/books/:id/diff      – fields changed between two history entries
+//	GET    /v1/books               – list all books (paginated)
+//	GET    /v1/books/recent        – list the newest books
+//	GET    /v1/books/random        – one book picked at random
+//	GET    /v1/books/feed.atom     – Atom feed of the newest books
+//	GET    /v1/books/export.ndjson – every matching book, one JSON object per line
+//	GET    /v1/books/search        – combined filters, ranked by title relevance
+//	GET    /v1/books/schema        – field validation rules for building client forms
+//	PUT    /v1/books/:id           – fully replace an existing book
+//	PATCH  /v1/books/:id           – partially update an existing book
+//	PATCH  /v1/books/bulk          – set the same fields on many books at once
+//	DELETE /v1/books/:id           – delete a book by ID
+//	GET    /                       – welcome document linking to the main resources
+//	GET    /v1/healthcheck         – application status and DB pool statistics
+//	PUT    /v1/admin/maintenance   – turn maintenance mode on or off (API key required)
+//	GET    /v1/openapi.json        – OpenAPI 3 description of this API
+//	GET    /debug/ratelimit        – rate-limiter state for the caller (development only)
+//	OPTIONS <any route>            – empty 200 with an Allow header listing its methods
 func (app *applicationDependencies) routes() http.Handler {
 	router := httprouter.New()
 
@@ -28,15 +54,93 @@ func (app *applicationDependencies) routes() http.Handler {
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
-	// Book CRUD routes
-	router.HandlerFunc(http.MethodPost,   "/v1/books",     app.createBookHandler)
-	router.HandlerFunc(http.MethodGet,    "/v1/books/:id", app.showBookHandler)
-	router.HandlerFunc(http.MethodGet,    "/v1/books",     app.listBooksHandler)
-	router.HandlerFunc(http.MethodPut,    "/v1/books/:id", app.replaceBookHandler) // Full replacement
-	router.HandlerFunc(http.MethodPatch,  "/v1/books/:id", app.updateBookHandler)  // Partial update
-	router.HandlerFunc(http.MethodDelete, "/v1/books/:id", app.deleteBookHandler)
+	// Answer OPTIONS requests automatically. httprouter builds the Allow header
+	// from the routes registered for the path (e.g. "GET, OPTIONS, POST" for
+	// /v1/books), then calls GlobalOPTIONS to finish the 200 response with an
+	// empty body. A CORS middleware can add its preflight headers around this.
+	router.HandleOPTIONS = true
+	router.GlobalOPTIONS = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// Static GET paths that sit at the same position as :id (see withStatic).
+	bookPaths := map[string]http.HandlerFunc{
+		"export.ndjson": app.exportBooksHandler,
+		"feed.atom":     app.bookFeedHandler,
+		"random":        app.randomBookHandler,
+		"recent":        app.recentBooksHandler,
+		"schema":        app.bookSchemaHandler,
+		"search":        app.searchBooksHandler,
+	}
+
+	// Two-segment GET paths under /v1/books, registered as /v1/books/:id/:sub.
+	// bookSubPaths is matched on the first segment (:sub then carries the
+	// value, e.g. the ISBN); anything else is treated as a book ID and
+	// bookIDSubPaths is matched on the second segment.
+	bookSubPaths := map[string]http.HandlerFunc{
+		"isbn": app.showBookByISBNHandler,
+	}
+	bookIDSubPaths := map[string]http.HandlerFunc{
+		"citation": app.bookCitationHandler,
+		"diff":     app.bookDiffHandler,
+		"history":  app.bookHistoryHandler,
+	}
+
+	// Static PATCH paths at the :id position.
+	bookPatchPaths := map[string]http.HandlerFunc{
+		"bulk": app.bulkUpdateBooksHandler,
+	}
+
+	// Every route is mounted under the optional -base-path prefix (e.g. "/api"),
+	// so the API can sit behind a gateway without URL rewriting.
+	base := app.config.basePath
+
+	// Book CRUD routes. Write routes set their body size limit with limitBody;
+	// single-book bodies stay small, a future batch route can allow more.
+	router.HandlerFunc(http.MethodPost,   base+"/v1/books",          app.limitBody(maxBookBodyBytes, app.createBookHandler))
+	router.HandlerFunc(http.MethodGet,    base+"/v1/books/:id",      app.withStatic("id", bookPaths, app.showBookHandler))
+	router.HandlerFunc(http.MethodHead,   base+"/v1/books/:id",      app.headOnly(app.withStatic("id", bookPaths, app.showBookHandler)))
+	router.HandlerFunc(http.MethodGet,    base+"/v1/books/:id/:sub", app.withStatic("id", bookSubPaths, app.withStatic("sub", bookIDSubPaths, nil)))
+	router.HandlerFunc(http.MethodGet,    base+"/v1/books",          app.listBooksHandler)
+	router.HandlerFunc(http.MethodPut,    base+"/v1/books/:id",      app.limitBody(maxBookBodyBytes, app.replaceBookHandler)) // Full replacement
+	router.HandlerFunc(http.MethodPatch,  base+"/v1/books/:id",      app.limitBody(maxBookBodyBytes, app.withStatic("id", bookPatchPaths, app.updateBookHandler))) // Partial update
+	router.HandlerFunc(http.MethodDelete, base+"/v1/books/:id",      app.deleteBookHandler)
+
+	// Operational endpoints
+	router.HandlerFunc(http.MethodGet, base+"/", app.rootHandler)
+	router.HandlerFunc(http.MethodGet, base+"/v1/healthcheck", app.healthcheckHandler)
+	router.HandlerFunc(http.MethodPut, base+"/v1/admin/maintenance", app.setMaintenanceHandler)
+
+	// API documentation
+	router.HandlerFunc(http.MethodGet, base+"/v1/openapi.json", app.openAPIHandler)
+
+	// Development-only debugging endpoints; see debug.go.
+	if app.config.environment == "development" {
+		router.HandlerFunc(http.MethodGet, base+"/debug/ratelimit", app.rateLimitDebugHandler)
+	}
 
 	// Wrap with middleware: recoverPanic is outermost so it catches panics
-	// from rateLimit and router alike.
-	return app.recoverPanic(app.rateLimit(router))
-}
\ No newline at end of file
+	// from every other layer and the router alike.
+	return app.recoverPanic(app.checkHost(app.strictTransportSecurity(app.rateLimit(app.limitConcurrency(app.authenticate(app.readOnlyMode(app.maintenanceMode(app.chaosDelay(router)))))))))
+}
+
+// withStatic lets static path segments share a position with a named
+// parameter. httprouter panics if e.g. /v1/books/recent is registered next to
+// /v1/books/:id, so the static names are registered under the parameter
+// instead: when the value of param matches a key in static, that handler
+// serves the request; otherwise it falls through to next, or to a 404 when
+// next is nil (for paths made up only of static names).
+func (app *applicationDependencies) withStatic(param string, static map[string]http.HandlerFunc, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+		if handler, ok := static[params.ByName(param)]; ok {
+			handler(w, r)
+			return
+		}
+		if next == nil {
+			app.notFoundResponse(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,171 @@
+// cmd/api/schema.go
+// This file contains the optional JSON Schema check for book bodies. The
+// schema in book_schema.json is applied on top of the v.Check calls in the
+// handlers when -schema-validation is set, so constraints can be tightened in
+// one declarative place and rolled out gradually.
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+
+	"github.com/aoideee/lab4-tyshadaniels/internal/data"
+	"github.com/aoideee/lab4-tyshadaniels/internal/validator"
+)
+
+// bookSchemaJSON holds the raw bytes of book_schema.json.
+//
+//go:embed book_schema.json
+var bookSchemaJSON []byte
+
+// jsonSchema is the subset of JSON Schema understood by checkSchema: type,
+// required, properties, additionalProperties (as a boolean), minLength,
+// maxLength, minimum, maximum, and pattern. Other keywords are ignored.
+type jsonSchema struct {
+	Type                 string                 `json:"type"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	Pattern              string                 `json:"pattern"`
+
+	patternRX *regexp.Regexp // Compiled Pattern, set by compile
+}
+
+// createBookSchema is parsed once at start-up; a broken embedded schema is a
+// programming error, so it panics like regexp.MustCompile.
+var createBookSchema = mustParseSchema(bookSchemaJSON)
+
+// mustParseSchema decodes a schema document and compiles its patterns.
+func mustParseSchema(raw []byte) *jsonSchema {
+	var schema jsonSchema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		panic(fmt.Sprintf("schema: %v", err))
+	}
+	schema.compile()
+	return &schema
+}
+
+// compile compiles Pattern on s and every nested property schema.
+func (s *jsonSchema) compile() {
+	if s.Pattern != "" {
+		s.patternRX = regexp.MustCompile(s.Pattern)
+	}
+	for _, prop := range s.Properties {
+		prop.compile()
+	}
+}
+
+// checkBookSchema validates input against book_schema.json, recording each
+// violation in v keyed by field name. It does nothing unless
+// -schema-validation is set. Copies left nil is treated as absent.
+func (app *applicationDependencies) checkBookSchema(input data.CreateBookInput, v *validator.Validator) {
+	if !app.config.schemaValidation {
+		return
+	}
+
+	// Round-trip through JSON so the schema sees the same field names and
+	// types a client sends.
+	raw, err := json.Marshal(input)
+	if err != nil {
+		v.AddError("body", err.Error())
+		return
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		v.AddError("body", err.Error())
+		return
+	}
+	for key, value := range doc {
+		if value == nil {
+			delete(doc, key)
+		}
+	}
+
+	createBookSchema.checkObject(doc, v)
+}
+
+// checkObject applies the object keywords of s to doc.
+func (s *jsonSchema) checkObject(doc map[string]any, v *validator.Validator) {
+	for _, name := range s.Required {
+		if _, ok := doc[name]; !ok {
+			v.AddError(name, "must be provided")
+		}
+	}
+
+	for name, value := range doc {
+		prop, ok := s.Properties[name]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				v.AddError(name, "is not an allowed field")
+			}
+			continue
+		}
+		if msg := prop.checkValue(value); msg != "" {
+			v.AddError(name, msg)
+		}
+	}
+}
+
+// checkValue applies the scalar keywords of s to value and returns the first
+// violation as a validation message, or "" when value conforms.
+func (s *jsonSchema) checkValue(value any) string {
+	switch s.Type {
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return "must be a string"
+		}
+		length := len([]rune(str))
+		if s.MinLength != nil && length < *s.MinLength {
+			if *s.MinLength == 1 {
+				return "must be provided"
+			}
+			return fmt.Sprintf("must be at least %d characters long", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			return fmt.Sprintf("must not be more than %d characters long", *s.MaxLength)
+		}
+		if s.patternRX != nil && str != "" && !s.patternRX.MatchString(str) {
+			return fmt.Sprintf("must match the pattern %s", s.Pattern)
+		}
+
+	case "integer", "number":
+		num, ok := value.(float64)
+		if !ok {
+			return "must be a number"
+		}
+		if s.Type == "integer" && num != math.Trunc(num) {
+			return "must be an integer"
+		}
+		if s.Minimum != nil && num < *s.Minimum {
+			return fmt.Sprintf("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && num > *s.Maximum {
+			return fmt.Sprintf("must not be more than %v", *s.Maximum)
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return "must be a boolean"
+		}
+
+	case "object":
+		if _, ok := value.(map[string]any); !ok {
+			return "must be an object"
+		}
+
+	case "array":
+		if _, ok := value.([]any); !ok {
+			return "must be an array"
+		}
+	}
+
+	return ""
+}
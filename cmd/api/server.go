@@ -6,10 +6,11 @@ package main
 import (
 	"context"
 	"errors"
-	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 )
@@ -17,17 +18,30 @@ import (
 // serve builds the HTTP server, starts it in a background goroutine, then
 // blocks until it receives a SIGINT or SIGTERM signal. On signal receipt it
 // initiates a graceful shutdown: in-flight requests are given 20 seconds to
-// complete before the server is forcefully stopped.
+// complete before the server is forcefully stopped. The database monitor
+// (see monitorDB) runs for as long as serve does.
 func (app *applicationDependencies) serve() error {
 	// Configure the HTTP server.
 	apiServer := &http.Server{
-		Addr:         fmt.Sprintf(":%d", app.config.port),
+		Addr:         net.JoinHostPort(app.config.host, strconv.Itoa(app.config.port)),
 		Handler:      app.routes(),
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  app.config.server.idleTimeout,
+		ReadTimeout:  app.config.server.readTimeout,
+		WriteTimeout: app.config.server.writeTimeout,
 	}
 
+	// Watch the database in the background until serve returns.
+	monitorCtx, stopMonitor := context.WithCancel(context.Background())
+	monitorDone := make(chan struct{})
+	go func() {
+		app.monitorDB(monitorCtx)
+		close(monitorDone)
+	}()
+	defer func() {
+		stopMonitor()
+		<-monitorDone
+	}()
+
 	// shutdownErr receives any error returned by Shutdown().
 	shutdownErr := make(chan error)
 
@@ -53,11 +67,17 @@ func (app *applicationDependencies) serve() error {
 		shutdownErr <- apiServer.Shutdown(ctx)
 	}()
 
-	// Start the server. ListenAndServe always returns a non-nil error; we
-	// treat ErrServerClosed as normal (it means Shutdown was called).
-	app.logger.Info("starting server", "address", apiServer.Addr, "environment", app.config.environment)
+	// Start the server (over HTTPS when a certificate is configured).
+	// ListenAndServe always returns a non-nil error; we treat
+	// ErrServerClosed as normal (it means Shutdown was called).
+	app.logger.Info("starting server", "address", apiServer.Addr, "environment", app.config.environment, "tls", app.config.tlsEnabled())
 
-	err := apiServer.ListenAndServe()
+	var err error
+	if app.config.tlsEnabled() {
+		err = apiServer.ListenAndServeTLS(app.config.tls.certFile, app.config.tls.keyFile)
+	} else {
+		err = apiServer.ListenAndServe()
+	}
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
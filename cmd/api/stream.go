@@ -0,0 +1,85 @@
+// cmd/api/stream.go
+// This file contains the streamed JSON response path for large book pages.
+// The regular path (writeResponse) builds the whole page in memory and then
+// marshals it; here each book is encoded as its row is scanned.
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/aoideee/lab4-tyshadaniels/internal/data"
+)
+
+// streamBooks writes {"books": [...], "metadata": {...}} (or -envelope-key in
+// place of "books") for the given list query, encoding each book straight
+// from the result set. The metadata is written after the array because it is
+// only known once every row has been read. Output is compact rather than
+// indented.
+//
+// Headers are sent with the first book, so a query that fails up front still
+// gets a normal 500. A failure after that can only be logged; the client sees
+// a truncated body. For the same reason X-Total-Count and Link (see
+// setPaginationHeaders) are sent as trailers after the body. Books are
+// encoded in timeFormat (see withTimeFormat).
+func (app *applicationDependencies) streamBooks(w http.ResponseWriter, r *http.Request, criteria data.BookCriteria, filters data.Filters, timeFormat string) {
+	enc := json.NewEncoder(w)
+	started := false
+
+	// start sends the headers and opens the envelope and the books array.
+	start := func() {
+		w.Header().Add("Vary", "Accept")
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Trailer", "X-Total-Count, Link")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"` + app.envelopeKey("books") + `":[`))
+		started = true
+	}
+
+	first := true
+	metadata, err := app.models.Books.StreamAll(criteria, filters, func(book *data.Book) error {
+		if !started {
+			start()
+		}
+		if !first {
+			if _, err := w.Write([]byte(",")); err != nil {
+				return err
+			}
+		}
+		first = false
+		if len(filters.Columns) > 0 {
+			return enc.Encode(withTimeFormat(book.Project(filters.Columns), timeFormat))
+		}
+		return enc.Encode(withTimeFormat(book, timeFormat))
+	})
+	if err != nil {
+		if !started {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.logError(r, err)
+		return
+	}
+
+	if !started {
+		// Nothing has been written yet, so an out-of-range page can still
+		// get its error response.
+		beyond, lastPage, err := app.pageBeyondLast(criteria, filters)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if beyond {
+			app.pageOutOfRangeResponse(w, r, lastPage)
+			return
+		}
+		start()
+	}
+	w.Write([]byte(`],"metadata":`))
+	enc.Encode(metadata)
+	w.Write([]byte("}\n"))
+
+	// Declared in the Trailer header, so setting them now sends them after
+	// the body.
+	app.setPaginationHeaders(w, r, metadata)
+}
@@ -0,0 +1,46 @@
+// cmd/api/testutils_test.go
+// This file contains helpers shared by the cmd/api tests.
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// newTestApplication returns an applicationDependencies with a discarded
+// logger and the flag defaults the handlers under test rely on. Tests
+// change app.config as they need.
+func newTestApplication(t *testing.T) *applicationDependencies {
+	t.Helper()
+
+	app := &applicationDependencies{
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		clock:  realClock{},
+	}
+	app.config.environment = "development"
+	app.config.envelope = true
+	app.config.maxValidationErrors = 50
+	app.config.routingErrors.notFoundMessage = "the requested resource could not be found"
+	return app
+}
+
+// captureLogs points app's logger at a buffer and returns it, for tests that
+// check what was logged.
+func captureLogs(app *applicationDependencies) *bytes.Buffer {
+	var buf bytes.Buffer
+	app.logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	return &buf
+}
+
+// withParams returns r with httprouter URL parameters in its context, as the
+// router would set them for a matched route.
+func withParams(r *http.Request, params ...httprouter.Param) *http.Request {
+	ctx := context.WithValue(r.Context(), httprouter.ParamsKey, httprouter.Params(params))
+	return r.WithContext(ctx)
+}
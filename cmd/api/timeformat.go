@@ -0,0 +1,61 @@
+// cmd/api/timeformat.go
+// This file contains the ?time_format option, which lets clients that work in
+// epoch seconds get created_at and updated_at as integers instead of RFC3339.
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aoideee/lab4-tyshadaniels/internal/data"
+	"github.com/aoideee/lab4-tyshadaniels/internal/validator"
+)
+
+// The accepted ?time_format values. RFC3339 is the default.
+const (
+	timeFormatRFC3339 = "rfc3339"
+	timeFormatUnix    = "unix"
+)
+
+// readTimeFormat reads ?time_format, recording anything other than rfc3339 or
+// unix on v. XML responses always use RFC3339 (xs:dateTime), so for them the
+// value is validated but otherwise ignored.
+func (app *applicationDependencies) readTimeFormat(r *http.Request, v *validator.Validator) string {
+	format := app.readString(r.URL.Query(), "time_format", timeFormatRFC3339)
+	v.Check(validator.In(format, timeFormatRFC3339, timeFormatUnix), "time_format", "must be rfc3339 or unix")
+	if prefersXML(r) {
+		return timeFormatRFC3339
+	}
+	return format
+}
+
+// withTimeFormat returns the response value for a book, a list of books, or
+// their ?fields projections in the given time format. For rfc3339 it returns
+// value unchanged; for unix books are wrapped in data.UnixTimeBook and the
+// timestamps in projections are replaced by their Unix seconds.
+func withTimeFormat(value any, format string) any {
+	if format != timeFormatUnix {
+		return value
+	}
+	switch value := value.(type) {
+	case *data.Book:
+		return data.UnixTimeBook{Book: value}
+	case []*data.Book:
+		books := make([]data.UnixTimeBook, len(value))
+		for i, book := range value {
+			books[i] = data.UnixTimeBook{Book: book}
+		}
+		return books
+	case map[string]any:
+		for key, field := range value {
+			if t, ok := field.(time.Time); ok {
+				value[key] = t.Unix()
+			}
+		}
+	case []map[string]any:
+		for _, fields := range value {
+			withTimeFormat(fields, format)
+		}
+	}
+	return value
+}
@@ -0,0 +1,191 @@
+// internal/data/audit.go
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"reflect"
+	"time"
+)
+
+// Audit actions recorded by AuditModel.Record.
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// AuditEntry is one row of the audit_log table: a single create, update, or
+// delete of a book, with the book as it was before and after the change.
+// Entries are kept after the book itself is deleted.
+type AuditEntry struct {
+	XMLName   xml.Name        `json:"-" xml:"audit_entry"`
+	ID        int64           `json:"audit_id" xml:"audit_id"`     // Unique identifier assigned by the database
+	Action    string          `json:"action" xml:"action"`         // AuditCreate, AuditUpdate, or AuditDelete
+	BookID    int64           `json:"book_id" xml:"book_id"`       // The book that changed
+	Actor     string          `json:"actor" xml:"actor"`           // Who made the change, e.g. "api-key:1a2b3c4d" or "anonymous"
+	Before    json.RawMessage `json:"before" xml:"before"`         // The book before the change (null for a create)
+	After     json.RawMessage `json:"after" xml:"after"`           // The book after the change (null for a delete)
+	CreatedAt time.Time       `json:"created_at" xml:"created_at"` // When the change was recorded
+}
+
+// AuditModel wraps a database handle and provides methods for the audit_log table.
+type AuditModel struct {
+	DB DBTX // Connection pool, or a transaction when used via Models.WithTx
+}
+
+// Record writes an audit entry for a change to a book. before is nil for a
+// create and after is nil for a delete. Call it through Models.WithTx, using
+// the same transaction as the change itself, so a failed audit write also
+// rolls the change back.
+func (m AuditModel) Record(action string, bookID int64, actor string, before, after *Book) error {
+	beforeJSON, err := auditSnapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := auditSnapshot(after)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO audit_log (action, book_id, actor, before, after)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	_, err = m.DB.Exec(query, action, bookID, actor, beforeJSON, afterJSON)
+	return err
+}
+
+// ForBook returns every audit entry for bookID, oldest first.
+func (m AuditModel) ForBook(bookID int64) ([]*AuditEntry, error) {
+	query := `
+		SELECT audit_id, action, book_id, actor, before, after, created_at
+		FROM audit_log
+		WHERE book_id = $1
+		ORDER BY audit_id ASC`
+
+	rows, err := m.DB.Query(query, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var before, after []byte
+		err := rows.Scan(
+			&entry.ID,
+			&entry.Action,
+			&entry.BookID,
+			&entry.Actor,
+			&before,
+			&after,
+			utcTime{&entry.CreatedAt},
+		)
+		if err != nil {
+			return nil, err
+		}
+		entry.Before = nullableJSON(before)
+		entry.After = nullableJSON(after)
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Get returns the audit entry auditID for bookID. Returns ErrRecordNotFound
+// if there is no such entry, including when it belongs to another book.
+func (m AuditModel) Get(bookID, auditID int64) (*AuditEntry, error) {
+	query := `
+		SELECT audit_id, action, book_id, actor, before, after, created_at
+		FROM audit_log
+		WHERE audit_id = $1 AND book_id = $2`
+
+	var entry AuditEntry
+	var before, after []byte
+	err := m.DB.QueryRow(query, auditID, bookID).Scan(
+		&entry.ID,
+		&entry.Action,
+		&entry.BookID,
+		&entry.Actor,
+		&before,
+		&after,
+		utcTime{&entry.CreatedAt},
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	entry.Before = nullableJSON(before)
+	entry.After = nullableJSON(after)
+	return &entry, nil
+}
+
+// FieldChange is the old and new value of one book field between two
+// versions. A nil value means the field was absent, e.g. the book did not
+// exist yet or had been deleted.
+type FieldChange struct {
+	From any `json:"from" xml:"from,omitempty"`
+	To   any `json:"to" xml:"to,omitempty"`
+}
+
+// DiffSnapshots compares two book snapshots (the After of two audit entries)
+// and returns the fields whose values differ, keyed by JSON name. A null
+// snapshot has no fields, so every field of the other one is reported.
+func DiffSnapshots(from, to json.RawMessage) (map[string]FieldChange, error) {
+	var fromFields, toFields map[string]any
+	if err := json.Unmarshal(from, &fromFields); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(to, &toFields); err != nil {
+		return nil, err
+	}
+
+	// Snapshots written while auditSnapshot still used Book.MarshalJSON carry
+	// the computed isbn10; it follows isbn, so comparing it would only report
+	// a change that never happened.
+	delete(fromFields, "isbn10")
+	delete(toFields, "isbn10")
+
+	changes := map[string]FieldChange{}
+	for name, value := range fromFields {
+		if other, ok := toFields[name]; !ok || !reflect.DeepEqual(value, other) {
+			changes[name] = FieldChange{From: value, To: toFields[name]}
+		}
+	}
+	for name, value := range toFields {
+		if _, ok := fromFields[name]; !ok {
+			changes[name] = FieldChange{To: value}
+		}
+	}
+	return changes, nil
+}
+
+// auditSnapshot encodes book for a JSONB column; a nil book stores NULL.
+// Only stored fields are kept: the plain alias drops Book.MarshalJSON, so
+// output-only values such as isbn10 never reach the history.
+func auditSnapshot(book *Book) ([]byte, error) {
+	if book == nil {
+		return nil, nil
+	}
+	type storedBook Book
+	return json.Marshal((*storedBook)(book))
+}
+
+// nullableJSON turns a NULL JSONB column into a JSON null.
+func nullableJSON(b []byte) json.RawMessage {
+	if b == nil {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(b)
+}
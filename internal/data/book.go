@@ -2,24 +2,125 @@
 // for the library management system.
 package data
 
-import "time"
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"slices"
+	"time"
+)
+
+// DefaultCopies is the number of copies a new book starts with when the
+// client does not say otherwise.
+const DefaultCopies = 1
+
+// Field limits enforced when a book is created or changed. Handlers validate
+// against these and GET /v1/books/schema publishes them, so clients and the
+// server cannot drift apart. There is no fixed latest publication year: it is
+// the current year, which handlers read from their clock.
+const (
+	MaxTitleLength     = 255  // Size of the title column; -max-title-length may not exceed it
+	ISBNLength         = 13   // Exact length of an ISBN
+	MinPublicationYear = 1    // Earliest allowed publication year
+	MinMinimumAge      = 0    // Smallest allowed minimum_age
+	MinCopies          = 0    // Smallest allowed copies count
+	ISBN979FirstYear   = 2007 // First year books were issued 979-prefixed ISBNs (see -strict-isbn)
+)
 
 // Book represents a single book record stored in the database.
 // It maps directly to a row in the "books" table.
 type Book struct {
-	ID              int64     `json:"book_id"`        // Unique identifier assigned by the database
-	Title           string    `json:"title"`           // Title of the book
-	ISBN            string    `json:"isbn"`            // 13-digit ISBN identifier
-	Publisher       string    `json:"publisher"`       // Name of the publishing company
-	PublicationYear int       `json:"publication_year"` // Year the book was published
-	MinimumAge      int       `json:"minimum_age"`     // Minimum recommended reader age
-	Description     string    `json:"description,omitempty"` // Optional short description (omitted from JSON if empty)
-	CreatedAt       time.Time `json:"created_at"`     // Timestamp when the record was created
-	UpdatedAt       time.Time `json:"updated_at"`     // Timestamp when the record was last modified
+	XMLName         xml.Name  `json:"-" xml:"book"`
+	ID              int64     `json:"book_id" xml:"book_id"`        // Unique identifier assigned by the database
+	Title           string    `json:"title" xml:"title"`           // Title of the book
+	ISBN            string    `json:"isbn" xml:"isbn"`            // 13-digit ISBN identifier
+	Publisher       string    `json:"publisher" xml:"publisher"`       // Name of the publishing company
+	PublicationYear int       `json:"publication_year" xml:"publication_year"` // Year the book was published
+	MinimumAge      int       `json:"minimum_age" xml:"minimum_age"`     // Minimum recommended reader age
+	Description     string    `json:"description,omitempty" xml:"description,omitempty"` // Optional short description (omitted from JSON if empty)
+	ShelfLocation   string    `json:"shelf_location,omitempty" xml:"shelf_location,omitempty"` // Optional physical location, e.g. "A-12-3" (aisle-shelf-position)
+	Language        string    `json:"language,omitempty" xml:"language,omitempty"` // Optional ISO 639-1 code, e.g. "en" (empty = unspecified)
+	Copies          int       `json:"copies" xml:"copies"`          // Number of copies currently available to lend
+	CreatedAt       time.Time `json:"created_at" xml:"created_at"`     // Timestamp when the record was created
+	UpdatedAt       time.Time `json:"updated_at" xml:"updated_at"`     // Timestamp when the record was last modified
+}
+
+// BookColumns lists every books column in SELECT order. Each column name is
+// also the Book field's JSON name, so it doubles as the whitelist for
+// projections via Filters.Columns.
+var BookColumns = []string{
+	"book_id", "title", "isbn", "publisher", "publication_year", "minimum_age",
+	"description", "shelf_location", "language", "copies", "created_at", "updated_at",
+}
+
+// columnDest returns the Scan destination for one of BookColumns.
+func (b *Book) columnDest(column string) any {
+	switch column {
+	case "book_id":
+		return &b.ID
+	case "title":
+		return &b.Title
+	case "isbn":
+		return &b.ISBN
+	case "publisher":
+		return &b.Publisher
+	case "publication_year":
+		return &b.PublicationYear
+	case "minimum_age":
+		return &b.MinimumAge
+	case "description":
+		return nullString{&b.Description}
+	case "shelf_location":
+		return &b.ShelfLocation
+	case "language":
+		return &b.Language
+	case "copies":
+		return &b.Copies
+	case "created_at":
+		return utcTime{&b.CreatedAt}
+	case "updated_at":
+		return utcTime{&b.UpdatedAt}
+	}
+	return nil
+}
+
+// Project returns just the given columns of b, keyed by JSON name, for
+// responses to a list request with a column projection.
+func (b *Book) Project(columns []string) map[string]any {
+	fields := make(map[string]any, len(columns))
+	for _, column := range columns {
+		switch column {
+		case "book_id":
+			fields[column] = b.ID
+		case "title":
+			fields[column] = b.Title
+		case "isbn":
+			fields[column] = b.ISBN
+		case "publisher":
+			fields[column] = b.Publisher
+		case "publication_year":
+			fields[column] = b.PublicationYear
+		case "minimum_age":
+			fields[column] = b.MinimumAge
+		case "description":
+			fields[column] = b.Description
+		case "shelf_location":
+			fields[column] = b.ShelfLocation
+		case "language":
+			fields[column] = b.Language
+		case "copies":
+			fields[column] = b.Copies
+		case "created_at":
+			fields[column] = b.CreatedAt
+		case "updated_at":
+			fields[column] = b.UpdatedAt
+		}
+	}
+	return fields
 }
 
 // CreateBookInput holds the fields a client must supply when creating a new book.
-// All fields except Description are required.
+// All fields except Description, ShelfLocation, Language, and Copies are required.
 type CreateBookInput struct {
 	Title           string `json:"title"           validate:"required"`
 	ISBN            string `json:"isbn"            validate:"required,len=13"`
@@ -27,6 +128,9 @@ type CreateBookInput struct {
 	PublicationYear int    `json:"publication_year" validate:"required"`
 	MinimumAge      int    `json:"minimum_age"     validate:"required"`
 	Description     string `json:"description,omitempty"`
+	ShelfLocation   string `json:"shelf_location,omitempty"`
+	Language        string `json:"language,omitempty"`
+	Copies          *int   `json:"copies"` // Optional; a nil value means the default of 1
 }
 
 // UpdateBookInput holds the fields a client may supply when partially updating a book.
@@ -36,7 +140,206 @@ type UpdateBookInput struct {
 	Title           *string `json:"title"`
 	ISBN            *string `json:"isbn"             validate:"omitempty,len=13"`
 	Publisher       *string `json:"publisher"`
-	PublicationYear *int    `json:"publication_year" validate:"omitempty,lte=2026"`
+	PublicationYear *int    `json:"publication_year"`
 	MinimumAge      *int    `json:"minimum_age"      validate:"omitempty,min=0"`
-	Description     *string `json:"description"`
-}
\ No newline at end of file
+	Description     *string `json:"description"` // An explicit null decodes to "" (see UnmarshalJSON)
+	ShelfLocation   *string `json:"shelf_location"`
+	Language        *string `json:"language"`
+	Copies          *int    `json:"copies"           validate:"omitempty,min=0"`
+}
+
+// BulkUpdateBookInput is the body of PATCH /v1/books/bulk: the values in Set
+// are applied to every book listed in IDs.
+type BulkUpdateBookInput struct {
+	IDs []int64        `json:"ids"`
+	Set BulkBookFields `json:"set"`
+}
+
+// BulkBookFields are the fields that may be set across many books at once,
+// e.g. to correct a publisher's name throughout the catalogue. Fields that
+// identify a single book, such as title and isbn, are deliberately absent, so
+// sending them is rejected as an unknown field. As with UpdateBookInput, nil
+// means "leave unchanged".
+type BulkBookFields struct {
+	Publisher     *string `json:"publisher"`
+	MinimumAge    *int    `json:"minimum_age"`
+	ShelfLocation *string `json:"shelf_location"`
+	Language      *string `json:"language"`
+}
+
+// Apply copies the non-nil fields onto book.
+func (f BulkBookFields) Apply(book *Book) {
+	if f.Publisher != nil {
+		book.Publisher = *f.Publisher
+	}
+	if f.MinimumAge != nil {
+		book.MinimumAge = *f.MinimumAge
+	}
+	if f.ShelfLocation != nil {
+		book.ShelfLocation = *f.ShelfLocation
+	}
+	if f.Language != nil {
+		book.Language = *f.Language
+	}
+}
+
+// FieldError reports a problem with a single JSON field that is detected while
+// decoding a request body, before the normal validation rules run. Handlers
+// report it as a field-level validation failure rather than a bad request.
+type FieldError struct {
+	Field   string // JSON name of the offending field
+	Message string // Validation-style message, e.g. "must not be null"
+}
+
+// Error implements the error interface, e.g. "title must not be null".
+func (e *FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// requiredBookFields lists the CreateBookInput fields that may not be null.
+var requiredBookFields = []string{"title", "isbn", "publisher", "publication_year", "minimum_age"}
+
+// UnmarshalJSON decodes a create/replace request body. A plain decode turns
+// an explicit null into a zero value, so {"title": null} would only fail later
+// with a confusing "must be provided"; instead an explicit null on a required
+// field is reported as a *FieldError. camelCase field names are accepted too
+// (see normalizeBookFields); genuinely unknown fields are still rejected.
+func (in *CreateBookInput) UnmarshalJSON(b []byte) error {
+	b, err := normalizeBookFields(b)
+	if err != nil {
+		return err
+	}
+
+	// If the body is not a JSON object, skip the null check and let the
+	// decode below produce the usual error.
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(b, &fields) == nil {
+		for _, name := range requiredBookFields {
+			if raw, ok := fields[name]; ok && bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+				return &FieldError{Field: name, Message: "must not be null"}
+			}
+		}
+	}
+
+	// Decode into a local type (which has no UnmarshalJSON method) so we do
+	// not recurse. DisallowUnknownFields must be repeated here because the
+	// outer decoder's setting does not carry over to custom unmarshalers.
+	type createBookInput CreateBookInput
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	return dec.Decode((*createBookInput)(in))
+}
+
+// UnmarshalJSON decodes a partial-update request body, accepting camelCase
+// field names as well as the canonical snake_case ones and still rejecting
+// unknown fields. A plain decode treats {"description": null} the same as an
+// omitted description (a nil pointer); instead it decodes to a pointer to "",
+// so the update clears the stored description.
+func (in *UpdateBookInput) UnmarshalJSON(b []byte) error {
+	b, err := normalizeBookFields(b)
+	if err != nil {
+		return err
+	}
+
+	type updateBookInput UpdateBookInput
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode((*updateBookInput)(in)); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(b, &fields) == nil {
+		if raw, ok := fields["description"]; ok && bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			cleared := ""
+			in.Description = &cleared
+		}
+	}
+	return nil
+}
+
+// MergePatchBookInput is a partial update sent as a JSON Merge Patch
+// (RFC 7396, Content-Type application/merge-patch+json). Absent fields are
+// left unchanged as with UpdateBookInput, but null always means "remove":
+// it clears the optional text fields and is rejected, as a *FieldError, on
+// fields a book cannot be without.
+type MergePatchBookInput struct {
+	UpdateBookInput
+}
+
+// mergePatchClearable lists the fields a merge-patch null may clear.
+var mergePatchClearable = []string{"description", "shelf_location", "language"}
+
+// UnmarshalJSON decodes a merge-patch body with the same field names and
+// strictness as UpdateBookInput, then applies the RFC 7396 meaning of null.
+func (in *MergePatchBookInput) UnmarshalJSON(b []byte) error {
+	b, err := normalizeBookFields(b)
+	if err != nil {
+		return err
+	}
+	if err := in.UpdateBookInput.UnmarshalJSON(b); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	for name, raw := range fields {
+		if !bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			continue
+		}
+		if !slices.Contains(mergePatchClearable, name) {
+			return &FieldError{Field: name, Message: "must not be null"}
+		}
+		cleared := ""
+		switch name {
+		case "description":
+			in.Description = &cleared
+		case "shelf_location":
+			in.ShelfLocation = &cleared
+		case "language":
+			in.Language = &cleared
+		}
+	}
+	return nil
+}
+
+// bookFieldAliases maps the camelCase spelling of each multi-word book field
+// to its canonical JSON name. Single-word fields are the same in both styles.
+var bookFieldAliases = map[string]string{
+	"publicationYear": "publication_year",
+	"minimumAge":      "minimum_age",
+	"shelfLocation":   "shelf_location",
+}
+
+// normalizeBookFields rewrites camelCase keys in a JSON object to their
+// snake_case names so clients using either convention are understood. Other
+// keys are left untouched, so unknown fields still fail the strict decode.
+// Sending both spellings of one field is reported as a *FieldError. Input that
+// is not a JSON object is returned unchanged for the decoder to reject.
+func normalizeBookFields(b []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(b, &fields) != nil || fields == nil {
+		return b, nil
+	}
+
+	renamed := false
+	for alias, name := range bookFieldAliases {
+		raw, ok := fields[alias]
+		if !ok {
+			continue
+		}
+		if _, dup := fields[name]; dup {
+			return nil, &FieldError{Field: name, Message: "must not be given as both " + name + " and " + alias}
+		}
+		fields[name] = raw
+		delete(fields, alias)
+		renamed = true
+	}
+
+	if !renamed {
+		return b, nil
+	}
+	return json.Marshal(fields)
+}
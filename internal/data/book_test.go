@@ -0,0 +1,141 @@
+// internal/data/book_test.go
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCreateBookInputFieldAliases(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"snake_case", `{"title":"T","isbn":"9780306406157","publisher":"P","publication_year":2001,"minimum_age":8,"shelf_location":"A-12-3"}`},
+		{"camelCase", `{"title":"T","isbn":"9780306406157","publisher":"P","publicationYear":2001,"minimumAge":8,"shelfLocation":"A-12-3"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var in CreateBookInput
+			if err := json.Unmarshal([]byte(tt.body), &in); err != nil {
+				t.Fatal(err)
+			}
+			if in.PublicationYear != 2001 || in.MinimumAge != 8 || in.ShelfLocation != "A-12-3" {
+				t.Errorf("decoded %+v; want publication_year 2001, minimum_age 8, shelf_location A-12-3", in)
+			}
+		})
+	}
+}
+
+func TestUpdateBookInputFieldAliases(t *testing.T) {
+	var in UpdateBookInput
+	if err := json.Unmarshal([]byte(`{"publicationYear":1999,"shelfLocation":"B-1-1"}`), &in); err != nil {
+		t.Fatal(err)
+	}
+	if in.PublicationYear == nil || *in.PublicationYear != 1999 || in.ShelfLocation == nil || *in.ShelfLocation != "B-1-1" {
+		t.Errorf("decoded %+v; want publication_year 1999 and shelf_location B-1-1", in)
+	}
+}
+
+func TestBookInputRejectsUnknownField(t *testing.T) {
+	body := []byte(`{"title":"T","pubYear":2001}`)
+
+	var create CreateBookInput
+	if err := json.Unmarshal(body, &create); err == nil || !strings.Contains(err.Error(), `unknown field "pubYear"`) {
+		t.Errorf("CreateBookInput error = %v; want unknown field", err)
+	}
+	var update UpdateBookInput
+	if err := json.Unmarshal(body, &update); err == nil || !strings.Contains(err.Error(), `unknown field "pubYear"`) {
+		t.Errorf("UpdateBookInput error = %v; want unknown field", err)
+	}
+}
+
+func TestBookInputRejectsBothSpellings(t *testing.T) {
+	var in UpdateBookInput
+	err := json.Unmarshal([]byte(`{"minimum_age":5,"minimumAge":6}`), &in)
+
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "minimum_age" {
+		t.Errorf("error = %v; want a FieldError on minimum_age", err)
+	}
+}
+
+func TestUpdateBookInputDescription(t *testing.T) {
+	value := func(s string) *string { return &s }
+
+	tests := []struct {
+		name string
+		body string
+		want *string // nil = leave the stored description unchanged
+	}{
+		{"null clears", `{"description":null}`, value("")},
+		{"empty string clears", `{"description":""}`, value("")},
+		{"value sets", `{"description":"New text"}`, value("New text")},
+		{"omitted leaves unchanged", `{"title":"T"}`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var in UpdateBookInput
+			if err := json.Unmarshal([]byte(tt.body), &in); err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tt.want == nil && in.Description != nil:
+				t.Errorf("Description = %q; want nil", *in.Description)
+			case tt.want != nil && (in.Description == nil || *in.Description != *tt.want):
+				t.Errorf("Description = %v; want %q", in.Description, *tt.want)
+			}
+		})
+	}
+}
+
+func TestMergePatchBookInput(t *testing.T) {
+	t.Run("null clears optional fields", func(t *testing.T) {
+		var in MergePatchBookInput
+		if err := json.Unmarshal([]byte(`{"description":null,"shelf_location":null,"language":null}`), &in); err != nil {
+			t.Fatal(err)
+		}
+		for name, field := range map[string]*string{"description": in.Description, "shelf_location": in.ShelfLocation, "language": in.Language} {
+			if field == nil || *field != "" {
+				t.Errorf("%s = %v; want a pointer to \"\"", name, field)
+			}
+		}
+	})
+
+	t.Run("absent fields are untouched", func(t *testing.T) {
+		var in MergePatchBookInput
+		if err := json.Unmarshal([]byte(`{"title":"New title"}`), &in); err != nil {
+			t.Fatal(err)
+		}
+		if in.Title == nil || *in.Title != "New title" {
+			t.Errorf("Title = %v; want %q", in.Title, "New title")
+		}
+		if in.Description != nil || in.ShelfLocation != nil || in.Language != nil || in.Publisher != nil || in.Copies != nil {
+			t.Errorf("absent fields were set: %+v", in.UpdateBookInput)
+		}
+	})
+
+	t.Run("null on a required field is rejected", func(t *testing.T) {
+		var in MergePatchBookInput
+		err := json.Unmarshal([]byte(`{"title":null}`), &in)
+
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Field != "title" {
+			t.Errorf("error = %v; want a FieldError on title", err)
+		}
+	})
+
+	t.Run("camelCase null clears", func(t *testing.T) {
+		var in MergePatchBookInput
+		if err := json.Unmarshal([]byte(`{"shelfLocation":null}`), &in); err != nil {
+			t.Fatal(err)
+		}
+		if in.ShelfLocation == nil || *in.ShelfLocation != "" {
+			t.Errorf("ShelfLocation = %v; want a pointer to \"\"", in.ShelfLocation)
+		}
+	})
+}
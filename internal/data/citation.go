@@ -0,0 +1,44 @@
+// internal/data/citation.go
+package data
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Citation styles accepted by Book.Citation.
+const (
+	CitationAPA = "apa" // APA 7th edition
+	CitationMLA = "mla" // MLA 9th edition
+)
+
+// CitationStyles lists every style Book.Citation can format, for validation.
+var CitationStyles = []string{CitationAPA, CitationMLA}
+
+// Citation formats b as a plain-text reference in style, which must be one of
+// CitationStyles. Books have no author data yet, so both styles use their
+// no-author form; the title takes the author's place:
+//
+//	APA: Title. (Year). Publisher.
+//	MLA: Title. Publisher, Year.
+func (b *Book) Citation(style string) string {
+	title := sentence(b.Title)
+	year := strconv.Itoa(b.PublicationYear)
+
+	switch style {
+	case CitationMLA:
+		return title + " " + b.Publisher + ", " + year + "."
+	default:
+		return title + " (" + year + "). " + sentence(b.Publisher)
+	}
+}
+
+// sentence returns s with a closing period unless it already ends in
+// punctuation that finishes a sentence, e.g. a question mark in a title.
+func sentence(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, ".") || strings.HasSuffix(s, "?") || strings.HasSuffix(s, "!") {
+		return s
+	}
+	return s + "."
+}
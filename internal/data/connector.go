@@ -0,0 +1,58 @@
+// internal/data/connector.go
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// ErrDatabaseUnavailable is returned (wrapped around the driver error) when
+// PostgreSQL refuses a new connection because it is temporarily unable to
+// take one: max_connections has been reached (SQLSTATE 53300) or the server
+// is starting up or shutting down (57P03). Both clear up on their own, so
+// callers should ask clients to retry rather than report a failure.
+var ErrDatabaseUnavailable = errors.New("database unavailable")
+
+// NewConnector returns a connector for dsn that behaves like lib/pq's own
+// but reports the refusals above as ErrDatabaseUnavailable. Open the pool
+// with sql.OpenDB(connector). Connections are made lazily, so the error
+// reaches whichever model method needed the connection, unchanged by
+// database/sql.
+func NewConnector(dsn string) (driver.Connector, error) {
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return unavailableConnector{connector}, nil
+}
+
+// unavailableConnector wraps a *pq.Connector to classify connection errors.
+type unavailableConnector struct {
+	*pq.Connector
+}
+
+// Connect implements driver.Connector.
+func (c unavailableConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, classifyConnectError(err)
+	}
+	return conn, nil
+}
+
+// classifyConnectError wraps err in ErrDatabaseUnavailable when it is one of
+// PostgreSQL's "try again later" refusals, and returns it unchanged otherwise.
+func classifyConnectError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "53300", "57P03": // too_many_connections, cannot_connect_now
+			return fmt.Errorf("%w: %w", ErrDatabaseUnavailable, err)
+		}
+	}
+	return err
+}
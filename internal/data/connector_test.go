@@ -0,0 +1,37 @@
+// internal/data/connector_test.go
+package data
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestClassifyConnectError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		unavailable bool
+	}{
+		{"too_many_connections", &pq.Error{Code: "53300", Message: "sorry, too many clients already"}, true},
+		{"cannot_connect_now", &pq.Error{Code: "57P03", Message: "the database system is starting up"}, true},
+		{"wrapped driver error", fmt.Errorf("dial: %w", &pq.Error{Code: "53300"}), true},
+		{"invalid_password", &pq.Error{Code: "28P01", Message: "password authentication failed"}, false},
+		{"not a driver error", errors.New("connection refused"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyConnectError(tt.err)
+			if got := errors.Is(err, ErrDatabaseUnavailable); got != tt.unavailable {
+				t.Errorf("errors.Is(err, ErrDatabaseUnavailable) = %t; want %t", got, tt.unavailable)
+			}
+			// The driver error must survive, so its details still reach the log.
+			if !errors.Is(err, tt.err) {
+				t.Errorf("classified error %v no longer wraps %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// internal/data/fakedb_test.go
+// This file contains a minimal database/sql driver for model tests that need
+// a result set but no PostgreSQL: every query returns the rows it was given,
+// and the queries and their arguments are recorded for inspection.
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// fakeDB answers every query with columns and rows. Each row holds
+// driver.Values (int64, string, time.Time, or nil for NULL).
+type fakeDB struct {
+	columns []string
+	rows    [][]driver.Value
+	err     error // Returned by every query instead of rows, if set
+
+	queries []string // Every query run, in order
+	args    [][]driver.NamedValue
+}
+
+// open returns a *sql.DB backed by f, closed when the test ends.
+func (f *fakeDB) open(t testing.TB) *sql.DB {
+	t.Helper()
+	db := sql.OpenDB(fakeConnector{f})
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+type fakeConnector struct{ db *fakeDB }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn(c), nil }
+func (c fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakedb: Prepare is not supported")
+}
+func (c fakeConn) Close() error { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("fakedb: transactions are not supported")
+}
+
+// QueryContext implements driver.QueryerContext, so database/sql never
+// needs Prepare.
+func (c fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.queries = append(c.db.queries, query)
+	c.db.args = append(c.db.args, args)
+	if c.db.err != nil {
+		return nil, c.db.err
+	}
+	return &fakeRows{columns: c.db.columns, rows: c.db.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next == len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
+
+// fakeBookRow returns a books row for id in BookColumns order.
+func fakeBookRow(id int64, description driver.Value) []driver.Value {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []driver.Value{
+		id, "Title", "9780306406157", "Publisher", int64(2001), int64(8),
+		description, "A-12-3", "en", int64(1), created, created,
+	}
+}
+
+// fakeListRows returns n rows as StreamAll selects them: the count and
+// max(updated_at) window values followed by every book column.
+func fakeListRows(n int) (columns []string, rows [][]driver.Value) {
+	columns = append([]string{"count", "max"}, BookColumns...)
+	for i := range n {
+		row := fakeBookRow(int64(i+1), "A description")
+		rows = append(rows, append([]driver.Value{int64(n), row[11]}, row...))
+	}
+	return columns, rows
+}
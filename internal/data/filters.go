@@ -0,0 +1,110 @@
+// internal/data/filters.go
+package data
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Filters holds pagination and sorting parameters extracted from URL query strings.
+// Nothing in it is specific to books: a model's list method embeds or accepts
+// a Filters, uses orderBy/limit/offset to build its query, and calls
+// calculateMetadata on the total row count.
+type Filters struct {
+	Page         int      // Current page number (1-indexed)
+	PageSize     int      // Number of records per page
+	Sort         string   // Comma-separated columns to sort by (prefix each with "-" for DESC)
+	SortSafeList []string // Allowed sort columns to prevent SQL injection
+	DefaultSort  string   // Sort token used when Sort is empty, e.g. "book_id" or "-book_id"
+	Columns      []string // Columns to SELECT (nil = all); the model checks them against its own list
+}
+
+// ErrInvalidSort is returned by list methods when a Sort token is not in
+// SortSafeList. Handlers validate sort first, so reaching it is a caller bug.
+var ErrInvalidSort = errors.New("invalid sort field")
+
+// sortFields splits Sort (or DefaultSort when Sort is empty) into its
+// comma-separated tokens, e.g. "title,-publication_year" becomes
+// ["title", "-publication_year"].
+func (f Filters) sortFields() []string {
+	if f.Sort == "" {
+		return strings.Split(f.DefaultSort, ",")
+	}
+	return strings.Split(f.Sort, ",")
+}
+
+// sortColumn returns the column name for a single sort token, or
+// ErrInvalidSort when the token is not in SortSafeList. Only safe-listed
+// names ever reach the SQL, which is what keeps ORDER BY injection-proof.
+func (f Filters) sortColumn(field string) (string, error) {
+	for _, safe := range f.SortSafeList {
+		if field == safe {
+			return strings.TrimPrefix(field, "-"), nil
+		}
+	}
+	return "", fmt.Errorf("data: %w %q", ErrInvalidSort, field)
+}
+
+// sortDirection returns "ASC" or "DESC" based on the prefix of a single sort token.
+func (f Filters) sortDirection(field string) string {
+	if strings.HasPrefix(field, "-") {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+// orderBy builds the column list for ORDER BY from every sort token,
+// e.g. "title ASC, publication_year DESC". It fails with ErrInvalidSort on
+// the first token that is not safe-listed.
+func (f Filters) orderBy() (string, error) {
+	fields := f.sortFields()
+	clauses := make([]string, 0, len(fields))
+	for _, field := range fields {
+		column, err := f.sortColumn(field)
+		if err != nil {
+			return "", err
+		}
+		clauses = append(clauses, column+" "+f.sortDirection(field))
+	}
+	return strings.Join(clauses, ", "), nil
+}
+
+// limit returns the SQL LIMIT value derived from PageSize.
+func (f Filters) limit() int { return f.PageSize }
+
+// offset returns the SQL OFFSET value derived from Page and PageSize.
+func (f Filters) offset() int { return (f.Page - 1) * f.PageSize }
+
+// Metadata contains pagination information returned alongside list responses.
+type Metadata struct {
+	CurrentPage  int `json:"current_page,omitempty" xml:"current_page,omitempty"`
+	PageSize     int `json:"page_size,omitempty" xml:"page_size,omitempty"`
+	FirstPage    int `json:"first_page,omitempty" xml:"first_page,omitempty"`
+	LastPage     int `json:"last_page,omitempty" xml:"last_page,omitempty"`
+	TotalRecords int `json:"total_records,omitempty" xml:"total_records,omitempty"`
+
+	// TotalRecordsEstimated is set when TotalRecords (and so LastPage) comes
+	// from the planner's row estimate instead of an exact count.
+	TotalRecordsEstimated bool `json:"total_records_estimated,omitempty" xml:"total_records_estimated,omitempty"`
+
+	// LastModified is the newest updated_at across every matching record (not
+	// just this page). It is not serialized; handlers use it for Last-Modified.
+	LastModified time.Time `json:"-" xml:"-"`
+}
+
+// calculateMetadata computes page metadata from total record count and filter values.
+func calculateMetadata(totalRecords, page, pageSize int) Metadata {
+	if totalRecords == 0 {
+		return Metadata{}
+	}
+	return Metadata{
+		CurrentPage:  page,
+		PageSize:     pageSize,
+		FirstPage:    1,
+		LastPage:     int(math.Ceil(float64(totalRecords) / float64(pageSize))),
+		TotalRecords: totalRecords,
+	}
+}
@@ -0,0 +1,50 @@
+// internal/data/filters_test.go
+package data
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFiltersOrderBy(t *testing.T) {
+	safe := []string{"book_id", "title", "-book_id", "-title"}
+
+	tests := []struct {
+		name    string
+		sort    string
+		want    string
+		wantErr error
+	}{
+		{"single field", "title", "title ASC", nil},
+		{"descending", "-book_id", "book_id DESC", nil},
+		{"several fields", "title,-book_id", "title ASC, book_id DESC", nil},
+		{"empty uses default", "", "book_id DESC", nil},
+		{"unknown field", "nope", "", ErrInvalidSort},
+		{"unknown field after a valid one", "title,nope", "", ErrInvalidSort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Filters{Sort: tt.sort, SortSafeList: safe, DefaultSort: "-book_id"}
+
+			got, err := f.orderBy()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("orderBy() error = %v; want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("orderBy() = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamAllRejectsInvalidSort(t *testing.T) {
+	// The sort is checked before any query runs, so no database is needed.
+	var m BookModel
+	f := Filters{Page: 1, PageSize: 10, Sort: "nope", SortSafeList: []string{"book_id"}, DefaultSort: "book_id"}
+
+	_, err := m.StreamAll(BookCriteria{}, f, func(*Book) error { return nil })
+	if !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("StreamAll error = %v; want ErrInvalidSort", err)
+	}
+}
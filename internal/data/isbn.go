@@ -0,0 +1,44 @@
+// internal/data/isbn.go
+package data
+
+import "encoding/json"
+
+// ISBN10 converts a 978-prefixed ISBN-13 to its ISBN-10 form, e.g.
+// "9780306406157" becomes "0306406152". The check digit is recomputed with
+// the ISBN-10 mod-11 algorithm and may be "X". It returns "" when there is no
+// ISBN-10 equivalent: 979-prefixed ISBNs were never issued as ISBN-10, and
+// anything other than 13 digits is not a convertible ISBN-13.
+func ISBN10(isbn13 string) string {
+	if len(isbn13) != ISBNLength || isbn13[:3] != "978" {
+		return ""
+	}
+	for _, c := range isbn13 {
+		if c < '0' || c > '9' {
+			return ""
+		}
+	}
+
+	// The ISBN-10 body is the nine digits after the prefix; the ISBN-13 check
+	// digit is dropped. Weights run from 10 down to 2.
+	body := isbn13[3:12]
+	sum := 0
+	for i, c := range body {
+		sum += int(c-'0') * (10 - i)
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return body + "X"
+	}
+	return body + string(rune('0'+check))
+}
+
+// MarshalJSON encodes a book with its stored fields plus the computed isbn10
+// for older systems, omitted when ISBN10 has no conversion. isbn10 is output
+// only; the stored ISBN stays the 13-digit one.
+func (b Book) MarshalJSON() ([]byte, error) {
+	type book Book // Same fields without this method, so Marshal does not recurse
+	return json.Marshal(struct {
+		book
+		ISBN10 string `json:"isbn10,omitempty"`
+	}{book(b), ISBN10(b.ISBN)})
+}
@@ -0,0 +1,79 @@
+// internal/data/isbn_test.go
+package data
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestISBN10(t *testing.T) {
+	tests := []struct {
+		name string
+		isbn string
+		want string
+	}{
+		{"978 prefix", "9780306406157", "0306406152"},
+		{"978 prefix with X check digit", "9780804429573", "080442957X"},
+		{"979 prefix has no ISBN-10", "9791090636071", ""},
+		{"too short", "978030640615", ""},
+		{"not digits", "978030640615X", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ISBN10(tt.isbn); got != tt.want {
+				t.Errorf("ISBN10(%q) = %q; want %q", tt.isbn, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBookMarshalJSONISBN10(t *testing.T) {
+	tests := []struct {
+		name string
+		isbn string
+		want string // expected isbn10 member, or "" when it must be omitted
+	}{
+		{"978 includes isbn10", "9780306406157", `"isbn10":"0306406152"`},
+		{"979 omits isbn10", "9791090636071", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			js, err := json.Marshal(Book{ISBN: tt.isbn})
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tt.want == "" && strings.Contains(string(js), `"isbn10"`):
+				t.Errorf("got %s; want no isbn10", js)
+			case tt.want != "" && !strings.Contains(string(js), tt.want):
+				t.Errorf("got %s; want it to contain %s", js, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditSnapshotOmitsISBN10(t *testing.T) {
+	js, err := auditSnapshot(&Book{ISBN: "9780306406157"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(js), "isbn10") {
+		t.Errorf("auditSnapshot stored the computed isbn10: %s", js)
+	}
+}
+
+func TestDiffSnapshotsIgnoresISBN10(t *testing.T) {
+	from := json.RawMessage(`{"isbn":"9780306406157","title":"A"}`)
+	to := json.RawMessage(`{"isbn":"9780306406157","isbn10":"0306406152","title":"A"}`)
+
+	changes, err := DiffSnapshots(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("DiffSnapshots = %v; want no changes", changes)
+	}
+}
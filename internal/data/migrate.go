@@ -0,0 +1,219 @@
+// internal/data/migrate.go
+package data
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// migrationFiles holds every SQL migration, compiled into the binary so a
+// fresh deployment can create its schema without the migrate CLI.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migration is a single embedded "up" migration file.
+type migration struct {
+	version int64  // Sequence number from the file name prefix, e.g. 2 for 000002_...
+	name    string // File name, used in error messages
+}
+
+// The version table uses the same layout as golang-migrate's schema_migrations
+// table, so the embedded runner and the Makefile's db/migrations/* targets can
+// be used interchangeably against the same database.
+const createSchemaMigrations = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT NOT NULL PRIMARY KEY,
+		dirty   BOOLEAN NOT NULL
+	)`
+
+// SchemaVersion reports the currently applied migration version and whether
+// the last migration was left half-applied ("dirty"). A database that has
+// never been migrated, including one without a schema_migrations table,
+// reports version 0. It only reads, so it works against a read-only replica
+// or role.
+func SchemaVersion(db *sql.DB) (int64, bool, error) {
+	var version int64
+	var dirty bool
+	err := db.QueryRow(`SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, false, nil
+		case errors.As(err, &pqErr) && pqErr.Code == "42P01": // undefined_table
+			return 0, false, nil
+		default:
+			return 0, false, err
+		}
+	}
+	return version, dirty, nil
+}
+
+// MissingColumns returns those of columns that table does not have, using
+// information_schema so it works on any PostgreSQL role that can see the
+// table. It lets the application check at startup that the columns it
+// refers to by name (e.g. in a sort safe list) really exist.
+func MissingColumns(db *sql.DB, table string, columns []string) ([]string, error) {
+	rows, err := db.Query(`
+		SELECT column_name
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		existing[name] = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, column := range columns {
+		if !existing[column] {
+			missing = append(missing, column)
+		}
+	}
+	return missing, nil
+}
+
+// CheckBooksTable verifies that the books table exists and has every one of
+// BookColumns by selecting them all with LIMIT 0, which reads no rows. It lets
+// a deployment pointed at an empty or wrong database fail at startup instead
+// of with a 500 on its first request; the error names the missing table or
+// columns.
+func CheckBooksTable(db *sql.DB) error {
+	_, err := db.Exec(`SELECT ` + strings.Join(BookColumns, ", ") + ` FROM books LIMIT 0`)
+	if err == nil {
+		return nil
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch pqErr.Code {
+	case "42P01": // undefined_table
+		return errors.New("data: the books table does not exist")
+	case "42703": // undefined_column
+		missing, mErr := MissingColumns(db, "books", BookColumns)
+		if mErr != nil || len(missing) == 0 {
+			return fmt.Errorf("data: books table: %s", pqErr.Message)
+		}
+		return fmt.Errorf("data: the books table is missing columns: %s", strings.Join(missing, ", "))
+	default:
+		return err
+	}
+}
+
+// MigrateUp applies, in order, every embedded up migration newer than the
+// current schema version and returns how many were applied. Each migration
+// runs in its own transaction together with the version bump, so a failure
+// leaves the schema at the last migration that succeeded.
+func MigrateUp(db *sql.DB) (int, error) {
+	_, err := db.Exec(createSchemaMigrations)
+	if err != nil {
+		return 0, err
+	}
+
+	current, dirty, err := SchemaVersion(db)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("schema is dirty at version %d; fix it by hand, then force the version with make db/migrations/fix", current)
+	}
+
+	migrations, err := upMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		err := applyMigration(db, m)
+		if err != nil {
+			return applied, fmt.Errorf("migration %s: %w", m.name, err)
+		}
+		applied++
+	}
+
+	return applied, nil
+}
+
+// applyMigration runs one migration file and records its version in a single transaction.
+func applyMigration(db *sql.DB, m migration) error {
+	body, err := migrationFiles.ReadFile(path.Join("migrations", m.name))
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once Commit has succeeded.
+	defer tx.Rollback()
+
+	_, err = tx.Exec(string(body))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, m.version)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// upMigrations lists the embedded "*.up.sql" files sorted by version.
+func upMigrations() ([]migration, error) {
+	names, err := fs.Glob(migrationFiles, "migrations/*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]migration, 0, len(names))
+	for _, name := range names {
+		name = path.Base(name)
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: file name must start with a sequence number", name)
+		}
+		migrations = append(migrations, migration{version: version, name: name})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	return migrations, nil
+}
@@ -0,0 +1,40 @@
+// internal/data/migrate_test.go
+package data
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestSchemaVersionIsReadOnly(t *testing.T) {
+	tests := []struct {
+		name        string
+		f           *fakeDB
+		wantVersion int64
+		wantDirty   bool
+	}{
+		{"no table", &fakeDB{err: &pq.Error{Code: "42P01"}}, 0, false},
+		{"empty table", &fakeDB{columns: []string{"version", "dirty"}}, 0, false},
+		{"migrated", &fakeDB{columns: []string{"version", "dirty"}, rows: [][]driver.Value{{int64(7), true}}}, 7, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, dirty, err := SchemaVersion(tt.f.open(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != tt.wantVersion || dirty != tt.wantDirty {
+				t.Errorf("SchemaVersion = %d, %t; want %d, %t", version, dirty, tt.wantVersion, tt.wantDirty)
+			}
+			for _, q := range tt.f.queries {
+				if !strings.HasPrefix(strings.TrimSpace(q), "SELECT") {
+					t.Errorf("SchemaVersion ran a non-SELECT statement:\n%s", q)
+				}
+			}
+		})
+	}
+}
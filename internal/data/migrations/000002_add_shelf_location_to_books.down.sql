@@ -0,0 +1 @@
+ALTER TABLE books DROP COLUMN IF EXISTS shelf_location;
\ No newline at end of file
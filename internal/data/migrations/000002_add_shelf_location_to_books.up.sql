@@ -0,0 +1 @@
+ALTER TABLE books ADD COLUMN IF NOT EXISTS shelf_location VARCHAR(20) NOT NULL DEFAULT '';
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE books ADD COLUMN IF NOT EXISTS copies INT NOT NULL DEFAULT 1 CHECK (copies >= 0);
\ No newline at end of file
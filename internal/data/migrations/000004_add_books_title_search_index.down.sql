@@ -0,0 +1 @@
+DROP INDEX IF EXISTS books_title_search_idx;
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS books_title_search_idx ON books USING GIN (to_tsvector('simple', title));
\ No newline at end of file
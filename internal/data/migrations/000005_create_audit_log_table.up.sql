@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    audit_id BIGSERIAL PRIMARY KEY,
+    action VARCHAR(10) NOT NULL,
+    book_id INT NOT NULL,
+    actor VARCHAR(100) NOT NULL,
+    before JSONB,
+    after JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_book_id_idx ON audit_log (book_id);
\ No newline at end of file
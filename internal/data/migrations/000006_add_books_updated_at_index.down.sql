@@ -0,0 +1 @@
+DROP INDEX IF EXISTS books_updated_at_idx;
\ No newline at end of file
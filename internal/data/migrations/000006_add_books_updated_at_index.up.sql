@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS books_updated_at_idx ON books (updated_at);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE books ADD COLUMN IF NOT EXISTS language VARCHAR(2) NOT NULL DEFAULT '';
\ No newline at end of file
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS books_weighted_search_idx;
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS books_weighted_search_idx ON books USING GIN ((setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', coalesce(description, '')), 'B')));
\ No newline at end of file
@@ -5,96 +5,259 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"math"
+	"log/slog"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 // Models is a top-level container that groups all database model types together.
 // It is passed around the application via applicationDependencies so every handler
 // has access to the database without importing sql directly.
 type Models struct {
-	Books BookModel // Handles all database operations for the books table
+	Books BookModel  // Handles all database operations for the books table
+	Audit AuditModel // Records and reads the audit_log table
+
+	db   *sql.DB      // Pool used to begin transactions in WithTx
+	slow slowQueryLog // Passed on to the models WithTx creates
 }
 
 // NewModels constructs a Models value wired up to the given database connection pool.
+// Book queries slower than slowQueryThreshold are logged to logger as warnings
+// (a zero threshold disables this), and counting decides how unfiltered book
+// lists compute their total.
 // Call this once during application startup and store the result in applicationDependencies.
-func NewModels(db *sql.DB) Models {
+func NewModels(db *sql.DB, logger *slog.Logger, slowQueryThreshold time.Duration, counting CountStrategy) Models {
+	slow := slowQueryLog{logger: logger, threshold: slowQueryThreshold}
 	return Models{
-		Books: BookModel{DB: db},
+		Books: BookModel{DB: db, slow: slow, counting: counting},
+		Audit: AuditModel{DB: db},
+		db:    db,
+		slow:  slow,
+	}
+}
+
+// slowQueryLog warns about queries that take longer than threshold.
+type slowQueryLog struct {
+	logger    *slog.Logger
+	threshold time.Duration
+}
+
+// start begins timing the query called name and returns a function that ends
+// it, so a whole method can be timed with
+//
+//	defer m.slow.start("books.get", "id", id)()
+//
+// attrs give context such as IDs or pagination; never pass raw user input
+// like search terms, which may be sensitive.
+func (s slowQueryLog) start(name string, attrs ...any) func() {
+	began := time.Now()
+	return func() {
+		if s.logger == nil || s.threshold <= 0 {
+			return
+		}
+		if elapsed := time.Since(began); elapsed >= s.threshold {
+			s.logger.Warn("slow query", append([]any{"query", name, "duration", elapsed}, attrs...)...)
+		}
+	}
+}
+
+// DBTX is the part of *sql.DB that the models use. *sql.Tx implements it too,
+// so the same model methods run inside or outside a transaction.
+type DBTX interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// WithTx runs fn with a copy of m whose models all share one transaction.
+// The transaction is committed if fn returns nil and rolled back otherwise,
+// so e.g. a book change and its audit entry are saved together or not at all.
+func (m Models) WithTx(fn func(tx Models) error) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once Commit has succeeded.
+	defer tx.Rollback()
+
+	err = fn(Models{
+		Books: BookModel{DB: tx, slow: m.slow, counting: m.Books.counting},
+		Audit: AuditModel{DB: tx},
+		slow:  m.slow,
+	})
+	if err != nil {
+		return err
 	}
+	return tx.Commit()
 }
 
 // ErrRecordNotFound is returned when a query finds no matching row.
 var ErrRecordNotFound = errors.New("record not found")
 
-// Filters holds pagination and sorting parameters extracted from URL query strings.
-type Filters struct {
-	Page         int      // Current page number (1-indexed)
-	PageSize     int      // Number of records per page
-	Sort         string   // Column name to sort by (prefix with "-" for DESC)
-	SortSafeList []string // Allowed sort columns to prevent SQL injection
+// ErrDuplicateISBN is returned by Insert and Update when another book already
+// has the ISBN.
+var ErrDuplicateISBN = errors.New("duplicate isbn")
+
+// ErrNoCopiesAvailable is returned by AdjustCopies when the change would
+// leave a book with fewer than zero copies.
+var ErrNoCopiesAvailable = errors.New("no copies available")
+
+// utcTime scans a timestamp column into *t converted to UTC. lib/pq returns
+// timestamptz values in the session time zone, which varies between
+// environments; normalising on scan means Book timestamps always serialize
+// as RFC3339 with a "Z" suffix.
+type utcTime struct {
+	t *time.Time
 }
 
-// sortColumn returns the validated column name for ORDER BY, defaulting to book_id.
-func (f Filters) sortColumn() string {
-	for _, safe := range f.SortSafeList {
-		if f.Sort == safe {
-			return strings.TrimPrefix(f.Sort, "-")
-		}
+// Scan implements sql.Scanner.
+func (u utcTime) Scan(src any) error {
+	t, ok := src.(time.Time)
+	if !ok {
+		return fmt.Errorf("data: cannot scan %T into a timestamp", src)
 	}
-	return "book_id" // safe fallback
+	*u.t = t.UTC()
+	return nil
 }
 
-// sortDirection returns "ASC" or "DESC" based on the Sort prefix.
-func (f Filters) sortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
-		return "DESC"
+// nullString scans a nullable text column into *s, storing NULL as "". The
+// API never writes NULL descriptions, but rows imported by other tools may
+// contain them and would otherwise fail to scan into a plain string.
+type nullString struct {
+	s *string
+}
+
+// Scan implements sql.Scanner.
+func (n nullString) Scan(src any) error {
+	var ns sql.NullString
+	if err := ns.Scan(src); err != nil {
+		return err
 	}
-	return "ASC"
+	*n.s = ns.String
+	return nil
 }
 
-// limit returns the SQL LIMIT value derived from PageSize.
-func (f Filters) limit() int { return f.PageSize }
+// BookCriteria holds the optional WHERE-clause filters for BookModel.GetAll.
+// A zero-value field means "do not filter on this column".
+type BookCriteria struct {
+	Shelf        string    // Exact shelf_location match, e.g. "A-12-3"
+	Language     string    // Exact language match, a lowercase ISO 639-1 code such as "en"
+	CreatedSince time.Time // Only books created at or after this instant
+	CreatedTo    time.Time // Only books created at or before this instant (the end of a range)
+	IDs          []int64   // Only books with one of these IDs (nil = any)
+	Query        string    // Full-text query on title and description; also ranks the results
+}
 
-// offset returns the SQL OFFSET value derived from Page and PageSize.
-func (f Filters) offset() int { return (f.Page - 1) * f.PageSize }
+// bookSearchVector is the weighted document BookCriteria.Query is matched
+// against: title words (weight A) count for more than description words
+// (weight B). It must match the expression of books_weighted_search_idx
+// exactly, or PostgreSQL cannot use the index.
+const bookSearchVector = `(setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', coalesce(description, '')), 'B'))`
+
+// where returns a whereBuilder holding the conditions and arguments for c.
+// Each optional filter adds a condition; callers take the clause (which is ""
+// with no filters set) and add any further arguments with arg. Query, when
+// set, is always $1 so that rankOrder can refer to it.
+func (c BookCriteria) where() *whereBuilder {
+	b := &whereBuilder{}
+	if c.Query != "" {
+		b.and(fmt.Sprintf("%s @@ plainto_tsquery('simple', %s)", bookSearchVector, b.arg(c.Query)))
+	}
+	if c.Shelf != "" {
+		b.and("shelf_location = " + b.arg(c.Shelf))
+	}
+	if c.Language != "" {
+		b.and("language = " + b.arg(c.Language))
+	}
+	if !c.CreatedSince.IsZero() {
+		b.and("created_at >= " + b.arg(c.CreatedSince))
+	}
+	if !c.CreatedTo.IsZero() {
+		b.and("created_at <= " + b.arg(c.CreatedTo))
+	}
+	if len(c.IDs) > 0 {
+		b.and(fmt.Sprintf("book_id = ANY(%s)", b.arg(pq.Array(c.IDs))))
+	}
+	return b
+}
 
-// Metadata contains pagination information returned alongside list responses.
-type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+// rankOrder returns the leading ORDER BY term that puts the most relevant
+// books first when c.Query is set, e.g. "ts_rank_cd(...) DESC, ", or "" so
+// the requested sort applies on its own. The requested sort then breaks ties.
+func (c BookCriteria) rankOrder() string {
+	if c.Query == "" {
+		return ""
+	}
+	return fmt.Sprintf("ts_rank_cd(%s, plainto_tsquery('simple', $1)) DESC, ", bookSearchVector)
 }
 
-// calculateMetadata computes page metadata from total record count and filter values.
-func calculateMetadata(totalRecords, page, pageSize int) Metadata {
-	if totalRecords == 0 {
-		return Metadata{}
+// selectedColumns returns filters.Columns, or every one of BookColumns when it
+// is empty, and rejects any name that is not a books column.
+func selectedColumns(filters Filters) ([]string, error) {
+	columns := filters.Columns
+	if len(columns) == 0 {
+		columns = BookColumns
 	}
-	return Metadata{
-		CurrentPage:  page,
-		PageSize:     pageSize,
-		FirstPage:    1,
-		LastPage:     int(math.Ceil(float64(totalRecords) / float64(pageSize))),
-		TotalRecords: totalRecords,
+	for _, column := range columns {
+		if !slices.Contains(BookColumns, column) {
+			return nil, fmt.Errorf("data: unknown books column %q", column)
+		}
 	}
+	return columns, nil
 }
 
-// BookModel wraps a *sql.DB connection and provides methods for
+// SearchFilters holds the criteria for BookModel.SearchBooks. Every field is
+// optional; a zero value (or nil for ReaderAge) leaves that column unfiltered.
+// Only Page and PageSize of the embedded Filters are used, because results
+// are always ordered by relevance.
+type SearchFilters struct {
+	Title         string // Full-text query on title; also ranks the results
+	CaseSensitive bool   // Match Title as an exact-case substring instead (no ranking)
+	Publisher     string // Case-insensitive substring match on publisher
+	YearFrom      int    // Earliest publication_year, inclusive
+	YearTo        int    // Latest publication_year, inclusive
+	ReaderAge     *int   // Only books whose minimum_age is at most this age
+	Filters
+}
+
+// BookModel wraps a database handle and provides methods for
 // creating, reading, updating, and deleting book records.
 type BookModel struct {
-	DB *sql.DB // Shared database connection pool
+	DB DBTX // Shared connection pool, or a transaction when used via Models.WithTx
+
+	slow     slowQueryLog  // Warns about slow queries; set by NewModels
+	counting CountStrategy // How StreamAll totals unfiltered lists; set by NewModels
+}
+
+// Count modes for CountStrategy.Mode.
+const (
+	CountExact    = "exact"    // Always COUNT(*) the matching rows
+	CountEstimate = "estimate" // Use the planner's row estimate for unfiltered lists
+	CountAuto     = "auto"     // Estimate only when the table is larger than Threshold
+)
+
+// CountStrategy decides how StreamAll computes total_records. An exact count
+// reads every matching row, which gets slow on very large tables; the
+// estimate comes from pg_class.reltuples, which VACUUM and ANALYZE keep
+// roughly current. Filtered lists are always counted exactly because the
+// estimate covers the whole table.
+type CountStrategy struct {
+	Mode      string // CountExact, CountEstimate, or CountAuto
+	Threshold int64  // In CountAuto, the estimated row count above which to estimate
 }
 
 // Insert adds a new book record to the database.
 // After a successful insert, the database-assigned book_id, created_at, and
 // updated_at values are written back into the book struct.
 func (m BookModel) Insert(book *Book) error {
+	defer m.slow.start("books.insert")()
+
 	query := `
-        INSERT INTO books (title, isbn, publisher, publication_year, minimum_age, description)
-        VALUES ($1, $2, $3, $4, $5, $6)
+        INSERT INTO books (title, isbn, publisher, publication_year, minimum_age, description, shelf_location, language, copies)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
         RETURNING book_id, created_at, updated_at
     `
 
@@ -107,24 +270,40 @@ func (m BookModel) Insert(book *Book) error {
 		book.PublicationYear,
 		book.MinimumAge,
 		book.Description,
-	).Scan(&book.ID, &book.CreatedAt, &book.UpdatedAt)
+		book.ShelfLocation,
+		book.Language,
+		book.Copies,
+	).Scan(&book.ID, utcTime{&book.CreatedAt}, utcTime{&book.UpdatedAt})
 
 	if err != nil {
-		return err
+		return duplicateISBN(err)
 	}
 
 	return nil
 }
 
+// duplicateISBN returns ErrDuplicateISBN if err is a books_isbn_key violation
+// and err unchanged otherwise.
+func duplicateISBN(err error) error {
+	// 23505 is unique_violation; isbn is the only unique column besides the key.
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "books_isbn_key" {
+		return ErrDuplicateISBN
+	}
+	return err
+}
+
 // Get retrieves a single book by its primary key.
 // Returns ErrRecordNotFound if no book with the given id exists.
 func (m BookModel) Get(id int64) (*Book, error) {
+	defer m.slow.start("books.get", "id", id)()
+
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
 	query := `
-		SELECT book_id, title, isbn, publisher, publication_year, minimum_age, description, created_at, updated_at
+		SELECT book_id, title, isbn, publisher, publication_year, minimum_age, description, shelf_location, language, copies, created_at, updated_at
 		FROM books
 		WHERE book_id = $1`
 
@@ -136,9 +315,12 @@ func (m BookModel) Get(id int64) (*Book, error) {
 		&book.Publisher,
 		&book.PublicationYear,
 		&book.MinimumAge,
-		&book.Description,
-		&book.CreatedAt,
-		&book.UpdatedAt,
+		nullString{&book.Description},
+		&book.ShelfLocation,
+		&book.Language,
+		&book.Copies,
+		utcTime{&book.CreatedAt},
+		utcTime{&book.UpdatedAt},
 	)
 	if err != nil {
 		switch {
@@ -151,42 +333,332 @@ func (m BookModel) Get(id int64) (*Book, error) {
 	return &book, nil
 }
 
-// GetAll retrieves a paginated, sorted list of books.
-// It uses a COUNT(*) OVER() window function so only one round-trip is needed.
+// GetByISBN fetches a single book by its ISBN. Returns ErrRecordNotFound if no row matches.
+func (m BookModel) GetByISBN(isbn string) (*Book, error) {
+	defer m.slow.start("books.get_by_isbn")()
+
+	query := `
+		SELECT book_id, title, isbn, publisher, publication_year, minimum_age, description, shelf_location, language, copies, created_at, updated_at
+		FROM books
+		WHERE isbn = $1
+		ORDER BY book_id
+		LIMIT 1`
+
+	var book Book
+	err := m.DB.QueryRow(query, isbn).Scan(
+		&book.ID,
+		&book.Title,
+		&book.ISBN,
+		&book.Publisher,
+		&book.PublicationYear,
+		&book.MinimumAge,
+		nullString{&book.Description},
+		&book.ShelfLocation,
+		&book.Language,
+		&book.Copies,
+		utcTime{&book.CreatedAt},
+		utcTime{&book.UpdatedAt},
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &book, nil
+}
+
+// GetAll retrieves a paginated, sorted list of books matching criteria.
 // Returns the book slice and pagination Metadata.
-func (m BookModel) GetAll(filters Filters) ([]*Book, Metadata, error) {
-	// Build query dynamically using the validated sort column and direction.
+func (m BookModel) GetAll(criteria BookCriteria, filters Filters) ([]*Book, Metadata, error) {
+	// StreamAll reuses one Book for every row, so each is copied into a
+	// backing array sized for the page: a page costs two allocations rather
+	// than one per book. A page never has more than PageSize rows, so the
+	// array is not regrown (and if it were, old pointers would stay valid).
+	backing := make([]Book, 0, filters.PageSize)
+	books := make([]*Book, 0, filters.PageSize)
+	metadata, err := m.StreamAll(criteria, filters, func(book *Book) error {
+		backing = append(backing, *book)
+		books = append(books, &backing[len(backing)-1])
+		return nil
+	})
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return books, metadata, nil
+}
+
+// StreamAll runs the same query as GetAll but hands each book to fn as soon
+// as its row is scanned instead of collecting a slice, so a large page can be
+// written out without holding it all in memory. Iteration stops at the first
+// error from fn, which is returned. The Metadata is only known once every row
+// has been read. The *Book passed to fn is reused for every row, so fn must
+// copy it to keep it beyond the call.
+//
+// It uses COUNT(*) OVER() and MAX(updated_at) OVER() window functions so the
+// total and the newest modification time need no extra round-trip. When
+// filters.Columns is set only those columns are selected; the other Book
+// fields are left as zero values.
+func (m BookModel) StreamAll(criteria BookCriteria, filters Filters, fn func(*Book) error) (Metadata, error) {
+	columns, err := selectedColumns(filters)
+	if err != nil {
+		return Metadata{}, err
+	}
+	orderBy, err := filters.orderBy()
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	b := criteria.where()
+	where := b.clause()
+	limit, offset := b.arg(filters.limit()), b.arg(filters.offset())
+
+	totalRecords := 0
+	var lastModified time.Time
+
+	// An estimated total replaces both window functions below: either one
+	// makes PostgreSQL read every matching row before returning the first.
+	estimated := false
+	if where == "" {
+		estimate, newest, ok, err := m.estimateTotal()
+		if err != nil {
+			return Metadata{}, err
+		}
+		if ok {
+			estimated = true
+			totalRecords, lastModified = estimate, newest
+		}
+	}
+
+	windows := "count(*) OVER(), max(updated_at) OVER(), "
+	if estimated {
+		windows = ""
+	}
+
+	// Build query dynamically using the validated sort columns and directions.
+	query := fmt.Sprintf(`
+		SELECT %s%s
+		FROM books
+		%s
+		ORDER BY %s%s, book_id ASC
+		LIMIT %s OFFSET %s`, windows, strings.Join(columns, ", "), where, criteria.rankOrder(), orderBy, limit, offset)
+
+	// Execute the SELECT and get a result set (rows). Only the query itself is
+	// timed: scanning runs at the pace of fn, e.g. a client reading a stream.
+	done := m.slow.start("books.list", "page", filters.Page, "page_size", filters.PageSize, "sort", filters.Sort)
+	rows, err := m.DB.Query(query, b.args...)
+	done()
+	if err != nil {
+		return Metadata{}, err
+	}
+	// Always close the result set when we are done to free the database connection.
+	defer rows.Close()
+
+	// Scan every row into the same Book through one set of destinations, built
+	// once, rather than allocating both per row. Each row sets every selected
+	// column, so nothing carries over from the previous one.
+	var book Book
+	var dest []any
+	if !estimated {
+		dest = append(dest,
+			&totalRecords, // COUNT(*) OVER() – same value on every row
+			&lastModified, // MAX(updated_at) OVER() – likewise
+		)
+	}
+	for _, column := range columns {
+		dest = append(dest, book.columnDest(column))
+	}
+	for rows.Next() {
+		err := rows.Scan(dest...)
+		if err != nil {
+			return Metadata{}, err
+		}
+		if err := fn(&book); err != nil {
+			return Metadata{}, err
+		}
+	}
+
+	// Check for any error that occurred while iterating the rows.
+	if err = rows.Err(); err != nil {
+		return Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	metadata.LastModified = lastModified
+	metadata.TotalRecordsEstimated = estimated && totalRecords > 0
+	return metadata, nil
+}
+
+// Export hands every book matching criteria to fn, in the order given by
+// filters, as its row is read. Unlike StreamAll there is no LIMIT, OFFSET, or
+// window function, so PostgreSQL sends rows as it finds them and the driver
+// reads them off the connection one at a time; neither side ever holds the
+// whole table. Page and PageSize are ignored. As with StreamAll the *Book is
+// reused for every row. Iteration stops at the first error from fn, which is
+// returned.
+func (m BookModel) Export(criteria BookCriteria, filters Filters, fn func(*Book) error) error {
+	columns, err := selectedColumns(filters)
+	if err != nil {
+		return err
+	}
+	orderBy, err := filters.orderBy()
+	if err != nil {
+		return err
+	}
+	b := criteria.where()
+
 	query := fmt.Sprintf(`
-		SELECT count(*) OVER(), book_id, title, isbn, publisher, publication_year, minimum_age, description, created_at, updated_at
+		SELECT %s
 		FROM books
-		ORDER BY %s %s, book_id ASC
-		LIMIT $1 OFFSET $2`, filters.sortColumn(), filters.sortDirection())
+		%s
+		ORDER BY %s%s, book_id ASC`, strings.Join(columns, ", "), b.clause(), criteria.rankOrder(), orderBy)
+
+	done := m.slow.start("books.export", "sort", filters.Sort)
+	rows, err := m.DB.Query(query, b.args...)
+	done()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var book Book
+	dest := make([]any, 0, len(columns))
+	for _, column := range columns {
+		dest = append(dest, book.columnDest(column))
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		if err := fn(&book); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Count returns the exact number of books matching criteria.
+func (m BookModel) Count(criteria BookCriteria) (int, error) {
+	defer m.slow.start("books.count")()
 
-	// Execute the SELECT and get a result set (rows).
-	rows, err := m.DB.Query(query, filters.limit(), filters.offset())
+	b := criteria.where()
+	var total int
+	err := m.DB.QueryRow(`SELECT count(*) FROM books `+b.clause(), b.args...).Scan(&total)
+	return total, err
+}
+
+// estimateTotal returns the planner's estimate of the number of books and the
+// newest updated_at, with ok reporting whether m.counting says to use them.
+// The estimate is unusable (ok is false) until the table has been analyzed,
+// when reltuples is -1. max(updated_at) is answered from
+// books_updated_at_idx rather than a scan.
+func (m BookModel) estimateTotal() (total int, newest time.Time, ok bool, err error) {
+	if m.counting.Mode != CountEstimate && m.counting.Mode != CountAuto {
+		return 0, time.Time{}, false, nil
+	}
+	defer m.slow.start("books.estimate_total")()
+
+	query := `
+		SELECT reltuples::bigint, (SELECT max(updated_at) FROM books)
+		FROM pg_class
+		WHERE oid = 'books'::regclass`
+
+	var estimate int64
+	var maxUpdated sql.NullTime
+	err = m.DB.QueryRow(query).Scan(&estimate, &maxUpdated)
+	if err != nil {
+		return 0, time.Time{}, false, err
+	}
+	if estimate < 0 || (m.counting.Mode == CountAuto && estimate <= m.counting.Threshold) {
+		return 0, time.Time{}, false, nil
+	}
+	return int(estimate), maxUpdated.Time, true, nil
+}
+
+// TitleExists reports whether any book already has the given title,
+// compared case-insensitively.
+func (m BookModel) TitleExists(title string) (bool, error) {
+	defer m.slow.start("books.title_exists")()
+
+	query := `SELECT EXISTS(SELECT 1 FROM books WHERE lower(title) = lower($1))`
+
+	var exists bool
+	err := m.DB.QueryRow(query, title).Scan(&exists)
+	return exists, err
+}
+
+// SearchBooks returns a page of books matching every criterion set in
+// filters. The WHERE clause is composed from whichever filters are present
+// (see whereBuilder). When a title query is given, results are ordered by
+// ts_rank so the best matches come first, with book_id as the stable
+// tiebreaker. A case-sensitive title match has no rank and is ordered by
+// book_id alone.
+func (m BookModel) SearchBooks(filters SearchFilters) ([]*Book, Metadata, error) {
+	defer m.slow.start("books.search", "page", filters.Page, "page_size", filters.PageSize)()
+
+	var b whereBuilder
+	orderBy := "book_id ASC"
+
+	switch {
+	case filters.Title != "" && filters.CaseSensitive:
+		// strpos rather than LIKE, so % and _ in the title are not wildcards.
+		b.and(fmt.Sprintf("strpos(title, %s) > 0", b.arg(filters.Title)))
+	case filters.Title != "":
+		title := b.arg(filters.Title)
+		b.and(fmt.Sprintf("to_tsvector('simple', title) @@ plainto_tsquery('simple', %s)", title))
+		orderBy = fmt.Sprintf("ts_rank(to_tsvector('simple', title), plainto_tsquery('simple', %s)) DESC, book_id ASC", title)
+	}
+	if filters.Publisher != "" {
+		// strpos rather than ILIKE, so % and _ in the publisher are not wildcards.
+		b.and(fmt.Sprintf("strpos(lower(publisher), lower(%s)) > 0", b.arg(filters.Publisher)))
+	}
+	if filters.YearFrom > 0 {
+		b.and("publication_year >= " + b.arg(filters.YearFrom))
+	}
+	if filters.YearTo > 0 {
+		b.and("publication_year <= " + b.arg(filters.YearTo))
+	}
+	if filters.ReaderAge != nil {
+		b.and("minimum_age <= " + b.arg(*filters.ReaderAge))
+	}
+
+	where := b.clause()
+	limit, offset := b.arg(filters.limit()), b.arg(filters.offset())
+
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), book_id, title, isbn, publisher, publication_year, minimum_age, description, shelf_location, language, copies, created_at, updated_at
+		FROM books
+		%s
+		ORDER BY %s
+		LIMIT %s OFFSET %s`, where, orderBy, limit, offset)
+
+	rows, err := m.DB.Query(query, b.args...)
 	if err != nil {
 		return nil, Metadata{}, err
 	}
-	// Always close the result set when we are done to free the database connection.
 	defer rows.Close()
 
 	totalRecords := 0
 	books := []*Book{}
 
-	// Iterate over each row and scan the columns into a Book struct.
 	for rows.Next() {
 		var book Book
 		err := rows.Scan(
-			&totalRecords, // COUNT(*) OVER() – same value on every row
+			&totalRecords,
 			&book.ID,
 			&book.Title,
 			&book.ISBN,
 			&book.Publisher,
 			&book.PublicationYear,
 			&book.MinimumAge,
-			&book.Description,
-			&book.CreatedAt,
-			&book.UpdatedAt,
+			nullString{&book.Description},
+			&book.ShelfLocation,
+			&book.Language,
+			&book.Copies,
+			utcTime{&book.CreatedAt},
+			utcTime{&book.UpdatedAt},
 		)
 		if err != nil {
 			return nil, Metadata{}, err
@@ -194,7 +666,6 @@ func (m BookModel) GetAll(filters Filters) ([]*Book, Metadata, error) {
 		books = append(books, &book)
 	}
 
-	// Check for any error that occurred while iterating the rows.
 	if err = rows.Err(); err != nil {
 		return nil, Metadata{}, err
 	}
@@ -203,9 +674,89 @@ func (m BookModel) GetAll(filters Filters) ([]*Book, Metadata, error) {
 	return books, metadata, nil
 }
 
+// Recent returns the limit most recently created books, newest first.
+func (m BookModel) Recent(limit int) ([]*Book, error) {
+	defer m.slow.start("books.recent", "limit", limit)()
+
+	query := `
+		SELECT book_id, title, isbn, publisher, publication_year, minimum_age, description, shelf_location, language, copies, created_at, updated_at
+		FROM books
+		ORDER BY created_at DESC, book_id DESC
+		LIMIT $1`
+
+	rows, err := m.DB.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	books := []*Book{}
+	for rows.Next() {
+		var book Book
+		err := rows.Scan(
+			&book.ID,
+			&book.Title,
+			&book.ISBN,
+			&book.Publisher,
+			&book.PublicationYear,
+			&book.MinimumAge,
+			nullString{&book.Description},
+			&book.ShelfLocation,
+			&book.Language,
+			&book.Copies,
+			utcTime{&book.CreatedAt},
+			utcTime{&book.UpdatedAt},
+		)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, &book)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+// Random returns one book chosen at random from those matching criteria, or
+// ErrRecordNotFound if none match. ORDER BY random() reads every matching row;
+// TABLESAMPLE would be cheaper on a huge table but can come back empty when
+// filters are applied, and the catalogue is small enough for the plain form.
+func (m BookModel) Random(criteria BookCriteria) (*Book, error) {
+	defer m.slow.start("books.random")()
+
+	b := criteria.where()
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM books
+		%s
+		ORDER BY random()
+		LIMIT 1`, strings.Join(BookColumns, ", "), b.clause())
+
+	var book Book
+	dest := make([]any, 0, len(BookColumns))
+	for _, column := range BookColumns {
+		dest = append(dest, book.columnDest(column))
+	}
+	err := m.DB.QueryRow(query, b.args...).Scan(dest...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &book, nil
+}
+
 // Delete removes the book with the given id from the database.
 // Returns ErrRecordNotFound if no matching record exists.
 func (m BookModel) Delete(id int64) error {
+	defer m.slow.start("books.delete", "id", id)()
+
 	// Guard against obviously bad IDs before touching the database.
 	if id < 1 {
 		return ErrRecordNotFound
@@ -235,12 +786,15 @@ func (m BookModel) Delete(id int64) error {
 // Update saves the modified fields of book back to the database.
 // The WHERE clause matches on book.ID, and the database automatically
 // updates the updated_at timestamp, which is scanned back into the struct.
+// Returns ErrDuplicateISBN if another book already has book.ISBN.
 func (m BookModel) Update(book *Book) error {
+	defer m.slow.start("books.update", "id", book.ID)()
+
 	query := `
 		UPDATE books 
 		SET title = $1, isbn = $2, publisher = $3, publication_year = $4, 
-            minimum_age = $5, description = $6, updated_at = CURRENT_TIMESTAMP
-		WHERE book_id = $7
+            minimum_age = $5, description = $6, shelf_location = $7, language = $8, copies = $9, updated_at = CURRENT_TIMESTAMP
+		WHERE book_id = $10
 		RETURNING updated_at`
 
 	// Collect all arguments in order matching the $N placeholders above.
@@ -251,9 +805,56 @@ func (m BookModel) Update(book *Book) error {
 		book.PublicationYear,
 		book.MinimumAge,
 		book.Description,
+		book.ShelfLocation,
+		book.Language,
+		book.Copies,
 		book.ID,
 	}
 
 	// Execute the UPDATE and scan the refreshed updated_at back into the struct.
-	return m.DB.QueryRow(query, args...).Scan(&book.UpdatedAt)
-}
\ No newline at end of file
+	err := m.DB.QueryRow(query, args...).Scan(utcTime{&book.UpdatedAt})
+	if err != nil {
+		return duplicateISBN(err)
+	}
+	return nil
+}
+
+// AdjustCopies atomically adds delta (which may be negative) to the number of
+// available copies of a book and returns the new count. The WHERE clause only
+// matches when the result stays at zero or above, so two concurrent checkouts
+// of the last copy cannot both succeed.
+// Returns ErrNoCopiesAvailable if the change would go negative, or
+// ErrRecordNotFound if no book with the given id exists.
+func (m BookModel) AdjustCopies(id int64, delta int) (int, error) {
+	defer m.slow.start("books.adjust_copies", "id", id)()
+
+	if id < 1 {
+		return 0, ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE books
+		SET copies = copies + $1, updated_at = CURRENT_TIMESTAMP
+		WHERE book_id = $2 AND copies + $1 >= 0
+		RETURNING copies`
+
+	var copies int
+	err := m.DB.QueryRow(query, delta, id).Scan(&copies)
+	if err == nil {
+		return copies, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	// No row matched: either the book is missing or it has too few copies.
+	var exists bool
+	err = m.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM books WHERE book_id = $1)`, id).Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, ErrRecordNotFound
+	}
+	return 0, ErrNoCopiesAvailable
+}
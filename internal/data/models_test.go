@@ -0,0 +1,175 @@
+// internal/data/models_test.go
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// listFilters is a valid first page of size pageSize.
+func listFilters(pageSize int) Filters {
+	return Filters{Page: 1, PageSize: pageSize, Sort: "book_id", SortSafeList: []string{"book_id"}, DefaultSort: "book_id"}
+}
+
+func TestGetNormalisesTimestampsToUTC(t *testing.T) {
+	// A session time zone other than UTC, as lib/pq would return it.
+	local := time.Date(2026, 1, 2, 3, 4, 5, 0, time.FixedZone("EST", -5*60*60))
+	row := fakeBookRow(1, "A description")
+	row[10], row[11] = local, local
+	f := &fakeDB{columns: BookColumns, rows: [][]driver.Value{row}}
+	m := BookModel{DB: f.open(t)}
+
+	book, err := m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := json.Marshal(book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"created_at":"2026-01-02T08:04:05Z"`, `"updated_at":"2026-01-02T08:04:05Z"`} {
+		if !strings.Contains(string(js), want) {
+			t.Errorf("got %s; want it to contain %s", js, want)
+		}
+	}
+}
+
+func TestScanNullDescription(t *testing.T) {
+	t.Run("Get", func(t *testing.T) {
+		f := &fakeDB{columns: BookColumns, rows: [][]driver.Value{fakeBookRow(1, nil)}}
+		book, err := BookModel{DB: f.open(t)}.Get(1)
+		if err != nil {
+			t.Fatalf("Get with a NULL description: %v", err)
+		}
+		if book.Description != "" {
+			t.Errorf("Description = %q; want empty", book.Description)
+		}
+	})
+
+	t.Run("GetAll", func(t *testing.T) {
+		columns, rows := fakeListRows(2)
+		rows[1][2+6] = nil // description of the second book
+		f := &fakeDB{columns: columns, rows: rows}
+		books, _, err := BookModel{DB: f.open(t)}.GetAll(BookCriteria{}, listFilters(10))
+		if err != nil {
+			t.Fatalf("GetAll with a NULL description: %v", err)
+		}
+		if books[0].Description != "A description" || books[1].Description != "" {
+			t.Errorf("descriptions = %q, %q; want %q, empty", books[0].Description, books[1].Description, "A description")
+		}
+	})
+}
+
+func TestGetAllReturnsDistinctBooks(t *testing.T) {
+	columns, rows := fakeListRows(3)
+	f := &fakeDB{columns: columns, rows: rows}
+	m := BookModel{DB: f.open(t)}
+
+	books, metadata, err := m.GetAll(BookCriteria{}, listFilters(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(books) != 3 || metadata.TotalRecords != 3 {
+		t.Fatalf("got %d books, total_records %d; want 3 and 3", len(books), metadata.TotalRecords)
+	}
+
+	// StreamAll reuses one Book for every row; GetAll must copy each one.
+	for i, book := range books {
+		if book.ID != int64(i+1) {
+			t.Errorf("books[%d].ID = %d; want %d", i, book.ID, i+1)
+		}
+		for j := range i {
+			if books[j] == book {
+				t.Errorf("books[%d] and books[%d] are the same pointer", j, i)
+			}
+		}
+	}
+}
+
+func BenchmarkGetAll(b *testing.B) {
+	columns, rows := fakeListRows(100)
+	f := &fakeDB{columns: columns, rows: rows}
+	m := BookModel{DB: f.open(b)}
+	filters := listFilters(100)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, _, err := m.GetAll(BookCriteria{}, filters); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGetAllPerRowCopy is the baseline for BenchmarkGetAll: the same
+// StreamAll scan, but collecting the page the way GetAll used to, with one
+// allocation per book and an unsized slice.
+func BenchmarkGetAllPerRowCopy(b *testing.B) {
+	columns, rows := fakeListRows(100)
+	f := &fakeDB{columns: columns, rows: rows}
+	m := BookModel{DB: f.open(b)}
+	filters := listFilters(100)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		var books []*Book
+		_, err := m.StreamAll(BookCriteria{}, filters, func(book *Book) error {
+			copied := *book
+			books = append(books, &copied)
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSearchBooksPublisherIsLiteral(t *testing.T) {
+	columns, rows := fakeListRows(0)
+	f := &fakeDB{columns: columns, rows: rows}
+	filters := SearchFilters{Publisher: `50%_off\`, Filters: listFilters(10)}
+
+	if _, _, err := (BookModel{DB: f.open(t)}).SearchBooks(filters); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.queries) != 1 {
+		t.Fatalf("ran %d queries; want 1", len(f.queries))
+	}
+	if q := f.queries[0]; strings.Contains(q, "LIKE") || !strings.Contains(q, "strpos(lower(publisher), lower($1)) > 0") {
+		t.Errorf("publisher filter is not a literal substring match:\n%s", q)
+	}
+	// The value is bound untouched: nothing in it needs escaping for strpos.
+	if got := f.args[0][0].Value; got != `50%_off\` {
+		t.Errorf("publisher argument = %q; want %q", got, `50%_off\`)
+	}
+}
+
+func TestUpdateDuplicateISBN(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"isbn taken", &pq.Error{Code: "23505", Constraint: "books_isbn_key"}, ErrDuplicateISBN},
+		{"other constraint", &pq.Error{Code: "23505", Constraint: "books_pkey"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeDB{columns: []string{"updated_at"}, err: tt.err}
+			err := BookModel{DB: f.open(t)}.Update(&Book{ID: 1})
+			want := tt.want
+			if want == nil {
+				want = tt.err
+			}
+			if !errors.Is(err, want) {
+				t.Errorf("Update error = %v; want %v", err, want)
+			}
+		})
+	}
+}
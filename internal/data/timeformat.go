@@ -0,0 +1,21 @@
+// internal/data/timeformat.go
+package data
+
+import "encoding/json"
+
+// UnixTimeBook encodes like Book (isbn10 included) except that created_at
+// and updated_at are whole seconds since the Unix epoch rather than RFC3339
+// strings. It is the ?time_format=unix form of a book.
+type UnixTimeBook struct{ *Book }
+
+// MarshalJSON encodes the wrapped book with integer timestamps. The outer
+// created_at and updated_at fields shadow the embedded ones.
+func (b UnixTimeBook) MarshalJSON() ([]byte, error) {
+	type book Book // Same fields without Book's MarshalJSON
+	return json.Marshal(struct {
+		book
+		ISBN10    string `json:"isbn10,omitempty"`
+		CreatedAt int64  `json:"created_at"`
+		UpdatedAt int64  `json:"updated_at"`
+	}{book(*b.Book), ISBN10(b.ISBN), b.CreatedAt.Unix(), b.UpdatedAt.Unix()})
+}
@@ -0,0 +1,47 @@
+// internal/data/where.go
+package data
+
+import (
+	"strconv"
+	"strings"
+)
+
+// whereBuilder composes a WHERE clause from optional conditions and collects
+// their arguments, numbering the "$N" placeholders itself so each filter can
+// add a condition without counting the ones before it. The usual pattern is
+//
+//	var b whereBuilder
+//	if shelf != "" {
+//		b.and("shelf_location = " + b.arg(shelf))
+//	}
+//	rows, err := db.Query("SELECT ... FROM books "+b.clause(), b.args...)
+//
+// Arguments for later parts of the query, such as LIMIT and OFFSET, are added
+// with arg too, after clause has been taken.
+type whereBuilder struct {
+	conditions []string
+	args       []any
+}
+
+// arg appends value to the query arguments and returns its placeholder, e.g.
+// "$3". A placeholder may be used more than once, e.g. in a condition and in
+// the ORDER BY.
+func (b *whereBuilder) arg(value any) string {
+	b.args = append(b.args, value)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+// and adds condition to the clause; conditions are joined with AND. Any
+// placeholders in it must come from arg.
+func (b *whereBuilder) and(condition string) {
+	b.conditions = append(b.conditions, condition)
+}
+
+// clause returns "WHERE " followed by the conditions, or "" when there are
+// none.
+func (b *whereBuilder) clause() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(b.conditions, " AND ")
+}
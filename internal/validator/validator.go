@@ -2,20 +2,62 @@
 // field-level validation errors and returning them as a map.
 package validator
 
-import "regexp"
+import (
+	_ "embed"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
 
 // EmailRX is a compiled regular expression for basic email validation.
 var EmailRX = regexp.MustCompile(`^[a-zA-Z0-9.!#$%&'*+/=?^_{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
 
+// ShelfLocationRX matches a physical shelf location in aisle-shelf-position
+// form, e.g. "A-12-3".
+var ShelfLocationRX = regexp.MustCompile(`^[A-Z]-\d{1,3}-\d{1,3}$`)
+
+// HostnameRX matches a DNS host name such as "localhost" or "api.example.com".
+var HostnameRX = regexp.MustCompile(`^[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+
+// iso6391 is the list of ISO 639-1 language codes, one lowercase code per line.
+//
+//go:embed iso639-1.txt
+var iso6391 string
+
+// languageCodes is the set of codes in iso6391, built once at startup.
+var languageCodes = func() map[string]bool {
+	codes := make(map[string]bool)
+	for _, code := range strings.Fields(iso6391) {
+		codes[code] = true
+	}
+	return codes
+}()
+
+// IsLanguageCode reports whether code is a two-letter ISO 639-1 language
+// code such as "en". The check ignores case, so "EN" is accepted too; callers
+// should store the lowercase form.
+func IsLanguageCode(code string) bool {
+	return languageCodes[strings.ToLower(code)]
+}
+
 // Validator holds a map of field names to their validation error messages.
 // A Validator with an empty Errors map is considered valid.
+//
+// Errors keeps only the first message per field. AllErrors keeps every
+// message, in the order they were added, for clients that want to see all
+// problems with a field at once.
 type Validator struct {
-	Errors map[string]string
+	Errors    map[string]string
+	AllErrors map[string][]string
 }
 
 // New creates and returns a fresh, empty Validator.
 func New() *Validator {
-	return &Validator{Errors: make(map[string]string)}
+	return &Validator{
+		Errors:    make(map[string]string),
+		AllErrors: make(map[string][]string),
+	}
 }
 
 // Valid returns true if the Errors map contains no entries.
@@ -24,12 +66,32 @@ func (v *Validator) Valid() bool {
 }
 
 // AddError records key as failing with the given message.
-// If key already has an error it is not overwritten, so the first
-// failure for a field is always the one that is reported.
+// If key already has an error it is not overwritten in Errors, so the first
+// failure for a field is always the one reported there; the message is still
+// appended to AllErrors unless it is a repeat.
 func (v *Validator) AddError(key, message string) {
 	if _, exists := v.Errors[key]; !exists {
 		v.Errors[key] = message
 	}
+	if !slices.Contains(v.AllErrors[key], message) {
+		v.AllErrors[key] = append(v.AllErrors[key], message)
+	}
+}
+
+// Truncate keeps the errors for at most max fields, dropping the rest from
+// both Errors and AllErrors, and reports whether any were dropped. Fields are
+// kept in alphabetical order, the order responses list them in, so the same
+// failures always truncate the same way. The Validator stays invalid.
+func (v *Validator) Truncate(max int) bool {
+	if len(v.Errors) <= max {
+		return false
+	}
+	keys := slices.Sorted(maps.Keys(v.Errors))
+	for _, key := range keys[max:] {
+		delete(v.Errors, key)
+		delete(v.AllErrors, key)
+	}
+	return true
 }
 
 // Check adds an error for key with message only when ok is false.
@@ -57,9 +119,9 @@ func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
 
-// Unique returns true if every string in values is distinct.
-func Unique(values []string) bool {
-	seen := make(map[string]bool)
+// Unique returns true if every value in values is distinct.
+func Unique[T comparable](values []T) bool {
+	seen := make(map[T]bool)
 	for _, v := range values {
 		if seen[v] {
 			return false
@@ -0,0 +1,24 @@
+// internal/validator/validator_test.go
+package validator
+
+import "testing"
+
+func TestIsLanguageCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"en", true},
+		{"EN", true}, // Case is ignored; callers store the lowercase form
+		{"fr", true},
+		{"xx", false},
+		{"eng", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsLanguageCode(tt.code); got != tt.want {
+			t.Errorf("IsLanguageCode(%q) = %t; want %t", tt.code, got, tt.want)
+		}
+	}
+}